@@ -13,12 +13,19 @@ import (
 // Version is the current version of gotestchunk
 const Version = "1.0.0" // x-release-please-version
 
+// configFile sets flag defaults when present in the working directory, keyed
+// by flag name, e.g. {"weights": [2, 2, 1, 1]}
+const configFile = ".gotestchunk.json"
+
 var cli struct {
 	Debug   bool `short:"d" help:"Enable debug logging"`
 	Version bool `short:"V" help:"Show version information"`
 
-	List commands.ListCmd `cmd:"" help:"List tests in packages"`
-	Test commands.TestCmd `cmd:"" help:"Run tests for a specific chunk" default:"withargs"`
+	List     commands.ListCmd     `cmd:"" help:"List tests in packages"`
+	Plan     commands.PlanCmd     `cmd:"" help:"Write a plan of every chunk's tests as JSON"`
+	Serve    commands.ServeCmd    `cmd:"" help:"Serve tests to workers started with test --coordinator"`
+	Simulate commands.SimulateCmd `cmd:"" help:"Report how tests would be spread across chunks"`
+	Test     commands.TestCmd     `cmd:"" help:"Run tests for a specific chunk" default:"withargs"`
 }
 
 func main() {
@@ -26,6 +33,14 @@ func main() {
 		kong.Name("gotestchunk"),
 		kong.Description("A tool for listing and chunking Go tests"),
 		kong.UsageOnError(),
+		kong.Configuration(kong.JSON, configFile),
+		// Usage errors exit with the same code as other errors
+		kong.Exit(func(code int) {
+			if code != 0 {
+				code = commands.ExitError
+			}
+			os.Exit(code)
+		}),
 		kong.Vars{
 			"version": Version,
 		},
@@ -49,6 +64,6 @@ func main() {
 	err := ctx.Run(&logger)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(commands.ExitCode(err))
 	}
 }
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/rs/zerolog"
+)
+
+// chunkerOptions are the flags shared by commands that chunk tests
+type chunkerOptions struct {
+	Granularity   string
+	Strategy      string
+	Estimator     string
+	ReadTiming    string        // Glob pattern of timing files
+	Weights       []float64     // Relative capacity of each chunk
+	Rules         string        // Rules file
+	SplitSubtests time.Duration // Split tests longer than this by subtest
+}
+
+// newChunker returns a chunker for the given flags, loading timings from
+// files matching ReadTiming if it is set
+func newChunker(logger *zerolog.Logger, opts chunkerOptions) (*testlist.Chunker, error) {
+	timings, err := readTimings(logger, opts.ReadTiming)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy, err := testlist.LookupStrategy(opts.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	estimator, err := testlist.NewEstimator(opts.Estimator, timings.Tests)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := loadRules(opts.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &testlist.Chunker{
+		Granularity:   testlist.Granularity(opts.Granularity),
+		Timings:       timings.Tests,
+		Overheads:     timings.Packages,
+		Strategy:      strategy,
+		Estimator:     estimator,
+		Capacities:    weights(opts.Weights),
+		Rules:         rules,
+		SplitSubtests: opts.SplitSubtests,
+	}, nil
+}
+
+// loadRules loads rules from a file, returning nil if filename is empty
+func loadRules(filename string) (*testlist.Rules, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	rules, err := testlist.LoadRules(filename)
+	if err != nil {
+		return nil, err
+	}
+	return rules, setRulesModule(rules)
+}
+
+// setRulesModule sets the module of rules, so they match full import paths
+func setRulesModule(rules *testlist.Rules) error {
+	if rules == nil {
+		return nil
+	}
+	module, err := testlist.ModuleName()
+	if err != nil {
+		return err
+	}
+	rules.Module = module
+	return nil
+}
+
+// warnUnmatchedRules warns about each rule that matches none of tests
+func warnUnmatchedRules(logger *zerolog.Logger, rules *testlist.Rules, tests []testlist.Test) {
+	for _, rule := range rules.Unmatched(tests) {
+		pattern := rule.Match
+		if pattern == "" {
+			pattern = rule.Regexp
+		}
+		logger.Warn().
+			Str("rule", pattern).
+			Msg("Rule matches no tests")
+	}
+}
+
+// validateWeights checks that there is a positive weight for each chunk, if
+// any weights are set
+func validateWeights(weights []float64, chunks int) error {
+	if len(weights) == 0 {
+		return nil
+	}
+	if len(weights) != chunks {
+		return fmt.Errorf("got %d weights for %d chunks", len(weights), chunks)
+	}
+	for i, weight := range weights {
+		if weight <= 0 {
+			return fmt.Errorf("weight of chunk %d must be positive", i+1)
+		}
+	}
+	return nil
+}
+
+// weights returns weights as chunker capacities, which are nil for an equal
+// share rather than empty
+func weights(weights []float64) []float64 {
+	if len(weights) == 0 {
+		return nil
+	}
+	return weights
+}
+
+// splitArgs splits arguments into packages and go test arguments at --,
+// defaulting to all packages in the module
+func splitArgs(args []string) (packages []string, testArgs []string) {
+	packages = args
+	for i, arg := range args {
+		if arg == "--" {
+			packages = args[:i]
+			testArgs = args[i+1:]
+			break
+		}
+	}
+
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	return packages, testArgs
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/lox/gotestchunk/pkg/testrunner"
+)
+
+// Exit codes, so CI can tell why a run failed. When packages fail in
+// different ways, the code is for the most severe outcome.
+const (
+	ExitTestFailure  = 1 // Tests failed
+	ExitBuildFailure = 2 // A package or its tests didn't build
+	ExitPanic        = 3 // A test panicked
+	ExitTimeout      = 4 // A test binary timed out
+	ExitError        = 5 // gotestchunk itself failed, such as with invalid flags
+	ExitInterrupted  = 6 // The run was stopped by an interrupt or termination signal
+)
+
+// exitCodes are the exit codes for each failed outcome
+var exitCodes = map[testrunner.Outcome]int{
+	testrunner.OutcomeTestFail:  ExitTestFailure,
+	testrunner.OutcomeBuildFail: ExitBuildFailure,
+	testrunner.OutcomePanic:     ExitPanic,
+	testrunner.OutcomeTimeout:   ExitTimeout,
+}
+
+// ExitCodeError is an error with the code that gotestchunk should exit with
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the code that gotestchunk should exit with for err
+func ExitCode(err error) int {
+	var exitErr *ExitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	var buildErr *testlist.BuildError
+	if errors.As(err, &buildErr) {
+		return ExitBuildFailure
+	}
+	return ExitError
+}
+
+// buildFailures returns a result for each package in err that couldn't be
+// listed because it doesn't build
+func buildFailures(err error) []testrunner.PackageResult {
+	var results []testrunner.PackageResult
+	switch err := err.(type) {
+	case *testlist.BuildError:
+		result := testrunner.PackageResult{Package: err.Package, Outcome: testrunner.OutcomeBuildFail}
+		for _, line := range strings.Split(strings.TrimSpace(err.Output), "\n") {
+			if !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "FAIL") {
+				result.Output = append(result.Output, line)
+			}
+		}
+		results = append(results, result)
+	case interface{ Unwrap() []error }:
+		for _, err := range err.Unwrap() {
+			results = append(results, buildFailures(err)...)
+		}
+	case interface{ Unwrap() error }:
+		results = append(results, buildFailures(err.Unwrap())...)
+	}
+	return results
+}
+
+// failedError returns err with the exit code for the worst outcome of the
+// failed packages, or err unchanged if no package failed, in which case the
+// error isn't down to the tests
+func failedError(err error, results []testrunner.PackageResult) error {
+	worst := testrunner.OutcomePass
+	for _, result := range results {
+		if result.Outcome.Worse(worst) {
+			worst = result.Outcome
+		}
+	}
+	if worst == testrunner.OutcomePass {
+		return err
+	}
+	return &ExitCodeError{Code: exitCodes[worst], Err: err}
+}
+
+// interruptedError returns err with the exit code for a run stopped by a
+// signal, as any failures are down to the interrupt
+func interruptedError(err error) error {
+	return &ExitCodeError{Code: ExitInterrupted, Err: err}
+}
+
+// failureCategories are the headings of the failure summary, in the order
+// they are written
+var failureCategories = []struct {
+	outcome testrunner.Outcome
+	heading string
+}{
+	{testrunner.OutcomeBuildFail, "Build failures"},
+	{testrunner.OutcomeTimeout, "Timeouts"},
+	{testrunner.OutcomePanic, "Panics"},
+	{testrunner.OutcomeTestFail, "Test failures"},
+}
+
+// writeFailureSummary writes the failed packages grouped by how they failed,
+// with the tests that failed in each and the output of build failures
+func writeFailureSummary(w io.Writer, results []testrunner.PackageResult) {
+	for _, category := range failureCategories {
+		var lines []string
+		for _, result := range results {
+			if result.Outcome != category.outcome {
+				continue
+			}
+			line := "  " + result.Package
+			if len(result.Tests) > 0 {
+				line += ": " + strings.Join(result.Tests, ", ")
+			}
+			lines = append(lines, line)
+			for _, output := range result.Output {
+				lines = append(lines, "      "+output)
+			}
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(w, "%s:\n%s\n", category.heading, strings.Join(lines, "\n"))
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lox/gotestchunk/pkg/coordinator"
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/lox/gotestchunk/pkg/testrunner"
+)
+
+func TestExitCode(t *testing.T) {
+	errFailed := errors.New("test command failed")
+	tests := []struct {
+		name    string
+		err     error
+		results []testrunner.PackageResult
+		want    int
+	}{
+		{
+			name: "error without failed packages",
+			err:  errFailed,
+			results: []testrunner.PackageResult{
+				{Package: "pkg/a", Outcome: testrunner.OutcomePass},
+			},
+			want: ExitError,
+		},
+		{
+			name: "test failure",
+			err:  errFailed,
+			results: []testrunner.PackageResult{
+				{Package: "pkg/a", Outcome: testrunner.OutcomePass},
+				{Package: "pkg/b", Outcome: testrunner.OutcomeTestFail},
+			},
+			want: ExitTestFailure,
+		},
+		{
+			name: "most severe outcome wins",
+			err:  errFailed,
+			results: []testrunner.PackageResult{
+				{Package: "pkg/a", Outcome: testrunner.OutcomeTimeout},
+				{Package: "pkg/b", Outcome: testrunner.OutcomeTestFail},
+				{Package: "pkg/c", Outcome: testrunner.OutcomePanic},
+			},
+			want: ExitTimeout,
+		},
+		{
+			name: "interrupted",
+			err:  interruptedError(fmt.Errorf("go test interrupted: %w", &testrunner.SignalError{Signal: os.Interrupt})),
+			want: ExitInterrupted,
+		},
+		{
+			name: "tests failed on workers",
+			err:  statusError(coordinator.Status{Failed: []string{"pkg/a.TestA"}}),
+			want: ExitTestFailure,
+		},
+		{
+			name: "batches errored on workers",
+			err:  statusError(coordinator.Status{Failed: []string{"pkg/a.TestA"}, Errors: []string{"lease lost"}}),
+			want: ExitError,
+		},
+		{
+			name: "build failure while listing",
+			err:  fmt.Errorf("error listing tests: %w", errors.Join(&testlist.BuildError{Package: "pkg/a"})),
+			want: ExitBuildFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(failedError(tt.err, tt.results)); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFailureSummary(t *testing.T) {
+	err := fmt.Errorf("error listing tests: %w", errors.Join(
+		errors.New("unrelated"),
+		&testlist.BuildError{Package: "pkg/d", Output: "# pkg/d\nd_test.go:1:1: expected 'package'\nFAIL\tpkg/d [setup failed]\n"},
+	))
+	results := append([]testrunner.PackageResult{
+		{Package: "pkg/a", Outcome: testrunner.OutcomeTestFail, Tests: []string{"TestOne", "TestTwo"}},
+		{Package: "pkg/b", Outcome: testrunner.OutcomePass},
+		{Package: "pkg/c", Outcome: testrunner.OutcomeTimeout, Tests: []string{"TestSlow"}},
+	}, buildFailures(err)...)
+
+	var b strings.Builder
+	writeFailureSummary(&b, results)
+
+	want := `Build failures:
+  pkg/d
+      d_test.go:1:1: expected 'package'
+Timeouts:
+  pkg/c: TestSlow
+Test failures:
+  pkg/a: TestOne, TestTwo
+`
+	if got := b.String(); got != want {
+		t.Errorf("writeFailureSummary() = %q, want %q", got, want)
+	}
+}
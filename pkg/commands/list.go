@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/lox/gotestchunk/pkg/ciparallel"
 	"github.com/lox/gotestchunk/pkg/testlist"
@@ -9,10 +10,20 @@ import (
 )
 
 type ListCmd struct {
-	Package string `arg:"" optional:"" help:"Package to list tests from" default:"."`
-	Chunks  int    `help:"Number of chunks to split tests into (defaults to CI value if available)" default:"1"`
-	Chunk   int    `help:"Which chunk to output (1-based, defaults to CI value if available)" default:"1"`
-	Format  string `help:"Output format (listTests|listPackages|runPattern)" default:"listTests" enum:"listTests,listPackages,runPattern"`
+	Chunks        int           `help:"Number of chunks to split tests into (defaults to CI value if available)" default:"1"`
+	Chunk         int           `help:"Which chunk to output (1-based, defaults to CI value if available)" default:"1"`
+	Format        string        `help:"Output format (listTests|listPackages|listFiles|runPattern|benchPattern)" default:"listTests" enum:"listTests,listPackages,listFiles,runPattern,benchPattern"`
+	Workers       int           `help:"Number of packages to discover tests in concurrently (defaults to GOMAXPROCS)" default:"0"`
+	Discovery     string        `help:"How to discover tests (compile|static)" default:"compile" enum:"compile,static"`
+	Kinds         []string      `help:"Kinds of tests to list (test|example|benchmark|fuzz)" default:"test"`
+	Granularity   string        `help:"Smallest group of tests assigned to a chunk (test|file|package)" default:"test" enum:"test,file,package"`
+	Strategy      string        `help:"How tests are assigned to chunks (auto|contiguous|round-robin|greedy|balanced|hash|package)" default:"auto"`
+	Weights       []float64     `help:"Relative capacity of each chunk, e.g. 2,2,1,1 gives the first two chunks twice the work of the others" env:"GOTESTCHUNK_WEIGHTS"`
+	Rules         string        `help:"Read rules that pin, isolate or group tests from this JSON file" default:""`
+	SplitSubtests time.Duration `help:"Split tests estimated to take longer than this into their subtests from timing data, so they can run in different chunks (0 to disable)" default:"0"`
+	ReadTiming    string        `help:"Read test timing information from files matching this glob pattern" default:""`
+	Estimator     string        `help:"How to estimate the duration of tests without timing data (default|package-median|suite-median|static)" default:"default"`
+	Args          []string      `arg:"" optional:"" passthrough:"" help:"Packages to list tests from, followed by optional -- and go test arguments, of which build flags such as -tags are used for discovery"`
 }
 
 func (cmd *ListCmd) Validate() error {
@@ -28,11 +39,41 @@ func (cmd *ListCmd) Validate() error {
 	if cmd.Chunk < 1 || cmd.Chunk > cmd.Chunks {
 		return fmt.Errorf("chunk must be between 1 and chunks")
 	}
+	if err := validateWeights(cmd.Weights, cmd.Chunks); err != nil {
+		return err
+	}
+	if _, err := testlist.ParseKinds(cmd.Kinds); err != nil {
+		return err
+	}
+	if _, err := testlist.NewEstimator(cmd.Estimator, nil); err != nil {
+		return err
+	}
+	if _, err := testlist.LookupStrategy(cmd.Strategy); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (cmd *ListCmd) Run(logger *zerolog.Logger) error {
-	tests, err := testlist.List(cmd.Package)
+	// Unlike test and plan, list defaults to the current package
+	packages, testArgs := splitArgs(cmd.Args)
+	if len(cmd.Args) == 0 || cmd.Args[0] == "--" {
+		packages = []string{"."}
+	}
+
+	kinds, err := testlist.ParseKinds(cmd.Kinds)
+	if err != nil {
+		return err
+	}
+
+	lister := &testlist.Lister{
+		Workers:    cmd.Workers,
+		Discovery:  testlist.Discovery(cmd.Discovery),
+		BuildFlags: testlist.BuildFlags(testArgs),
+		Kinds:      kinds,
+	}
+
+	tests, err := lister.List(packages...)
 	if err != nil {
 		return fmt.Errorf("error listing tests: %w", err)
 	}
@@ -48,12 +89,34 @@ func (cmd *ListCmd) Run(logger *zerolog.Logger) error {
 			Int("chunk", cmd.Chunk).
 			Msg("Chunking tests")
 
-		// Use 0-based index for testlist.Chunk
-		chunkTests, err := testlist.Chunk(tests, cmd.Chunk-1, cmd.Chunks)
+		chunker, err := newChunker(logger, chunkerOptions{
+			Granularity:   cmd.Granularity,
+			Strategy:      cmd.Strategy,
+			Estimator:     cmd.Estimator,
+			ReadTiming:    cmd.ReadTiming,
+			Weights:       cmd.Weights,
+			Rules:         cmd.Rules,
+			SplitSubtests: cmd.SplitSubtests,
+		})
+		if err != nil {
+			return err
+		}
+
+		if cmd.Chunk < 1 || cmd.Chunk > cmd.Chunks {
+			return fmt.Errorf("chunk %d out of bounds (total chunks: %d)", cmd.Chunk, cmd.Chunks)
+		}
+		warnUnmatchedRules(logger, chunker.Rules, tests)
+		chunks, err := chunker.Chunks(tests, cmd.Chunks)
 		if err != nil {
 			return fmt.Errorf("error chunking tests: %w", err)
 		}
-		tests = chunkTests
+
+		if chunker.Timings != nil {
+			logBalance(logger, chunker.Balance(chunks))
+		}
+
+		// Use 0-based index for chunks
+		tests = chunks[cmd.Chunk-1]
 	}
 
 	output, err := testlist.Format(tests, cmd.Format)
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -11,6 +13,11 @@ import (
 )
 
 func TestListCmd_Run(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(rules, []byte(`{"rules": [{"match": "**/pkg/example.TestWithSetup", "exclusive": true}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		name    string
 		cmd     ListCmd
@@ -20,8 +27,22 @@ func TestListCmd_Run(t *testing.T) {
 		{
 			name: "list all tests",
 			cmd: ListCmd{
-				Package: "./pkg/example/...",
-				Format:  "listTests",
+				Args:   []string{"./pkg/example/..."},
+				Format: "listTests",
+			},
+			want: `TestSimple
+TestParallel
+TestTableDriven
+TestWithSetup
+TestMath
+TestDivideErrors`,
+		},
+		{
+			name: "list all tests with static discovery",
+			cmd: ListCmd{
+				Args:      []string{"./pkg/example/..."},
+				Format:    "listTests",
+				Discovery: "static",
 			},
 			want: `TestSimple
 TestParallel
@@ -29,12 +50,46 @@ TestTableDriven
 TestWithSetup
 TestMath
 TestDivideErrors`,
+		},
+		{
+			name: "list tests with build tags",
+			cmd: ListCmd{
+				Args:   []string{"./pkg/testlist/testdata/static", "--", "-tags=integration"},
+				Format: "listTests",
+			},
+			want: `TestInternal
+TestIntegration
+TestExternal
+Test`,
+		},
+		{
+			name: "list several packages",
+			cmd: ListCmd{
+				Args:   []string{"./pkg/example/sub", "./pkg/testlist/testdata/static", "--", "-tags=integration"},
+				Format: "listTests",
+			},
+			want: `TestMath
+TestDivideErrors
+TestInternal
+TestIntegration
+TestExternal
+Test`,
+		},
+		{
+			name: "list benchmarks and examples",
+			cmd: ListCmd{
+				Args:   []string{"./pkg/example/..."},
+				Format: "listTests",
+				Kinds:  []string{"benchmark", "example"},
+			},
+			want: `BenchmarkMultiply
+ExampleMultiply`,
 		},
 		{
 			name: "list packages",
 			cmd: ListCmd{
-				Package: "./pkg/example/...",
-				Format:  "listPackages",
+				Args:   []string{"./pkg/example/..."},
+				Format: "listPackages",
 			},
 			want: `./pkg/example
 ./pkg/example/sub`,
@@ -42,38 +97,100 @@ TestDivideErrors`,
 		{
 			name: "run pattern",
 			cmd: ListCmd{
-				Package: "./pkg/example",
-				Format:  "runPattern",
+				Args:   []string{"./pkg/example"},
+				Format: "runPattern",
 			},
 			want: "^(TestSimple|TestParallel|TestTableDriven|TestWithSetup)$",
 		},
 		{
 			name: "chunk tests",
 			cmd: ListCmd{
-				Package: "./pkg/example/...",
+				Args:   []string{"./pkg/example/..."},
+				Format: "listTests",
+				Chunks: 2,
+				Chunk:  1,
+			},
+			want: `TestSimple
+TestParallel
+TestTableDriven`,
+		},
+		{
+			name: "weighted chunks",
+			cmd: ListCmd{
+				Args:    []string{"./pkg/example/..."},
 				Format:  "listTests",
 				Chunks:  2,
 				Chunk:   1,
+				Weights: []float64{2, 1},
 			},
 			want: `TestSimple
 TestParallel
-TestTableDriven`,
+TestTableDriven
+TestWithSetup`,
+		},
+		{
+			name: "exclusive test in a chunk of its own",
+			cmd: ListCmd{
+				Args:   []string{"./pkg/example/..."},
+				Format: "listTests",
+				Chunks: 2,
+				Chunk:  2,
+				Rules:  rules,
+			},
+			want: `TestWithSetup`,
+		},
+		{
+			name: "chunk packages",
+			cmd: ListCmd{
+				Args:        []string{"./pkg/example/..."},
+				Format:      "listTests",
+				Chunks:      2,
+				Chunk:       2,
+				Granularity: "package",
+			},
+			want: `TestMath
+TestDivideErrors`,
+		},
+		{
+			name: "list files",
+			cmd: ListCmd{
+				Args:        []string{"./pkg/example/..."},
+				Format:      "listFiles",
+				Chunks:      2,
+				Chunk:       2,
+				Granularity: "file",
+			},
+			want: `pkg/example/sub/sub_test.go`,
+		},
+		{
+			name: "hash strategy",
+			cmd: ListCmd{
+				Args:     []string{"./pkg/example/..."},
+				Format:   "listTests",
+				Chunks:   2,
+				Chunk:    1,
+				Strategy: "hash",
+			},
+			want: `TestParallel
+TestWithSetup
+TestDivideErrors
+TestMath`,
 		},
 		{
 			name: "invalid package",
 			cmd: ListCmd{
-				Package: "./does-not-exist",
-				Format:  "listTests",
+				Args:   []string{"./does-not-exist"},
+				Format: "listTests",
 			},
 			wantErr: true,
 		},
 		{
 			name: "invalid chunk",
 			cmd: ListCmd{
-				Package: "./pkg/example/...",
-				Format:  "listTests",
-				Chunks:  2,
-				Chunk:   3,
+				Args:   []string{"./pkg/example/..."},
+				Format: "listTests",
+				Chunks: 2,
+				Chunk:  3,
 			},
 			wantErr: true,
 		},
@@ -160,6 +277,33 @@ func TestListCmd_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "weight for each chunk",
+			cmd: ListCmd{
+				Chunks:  3,
+				Chunk:   1,
+				Weights: []float64{2, 1, 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "weights not matching chunks",
+			cmd: ListCmd{
+				Chunks:  3,
+				Chunk:   1,
+				Weights: []float64{2, 1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero weight",
+			cmd: ListCmd{
+				Chunks:  2,
+				Chunk:   1,
+				Weights: []float64{1, 0},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
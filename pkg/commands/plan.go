@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/rs/zerolog"
+)
+
+type PlanCmd struct {
+	Chunks         int           `help:"Number of chunks to split tests into" default:"1"`
+	TargetDuration time.Duration `help:"Use the fewest chunks that keep every chunk's estimated duration under this, rather than --chunks" default:"0"`
+	Output         string        `short:"o" help:"Write the plan to this file rather than stdout" default:""`
+	Format         string        `help:"What to print to stdout (plan|chunks), where chunks prints only the number of chunks" default:"plan" enum:"plan,chunks"`
+	Workers        int           `help:"Number of packages to discover tests in concurrently (defaults to GOMAXPROCS)" default:"0"`
+	Discovery      string        `help:"How to discover tests (compile|static)" default:"compile" enum:"compile,static"`
+	Kinds          []string      `help:"Kinds of tests to plan (test|example|benchmark|fuzz)" default:"test"`
+	Granularity    string        `help:"Smallest group of tests assigned to a chunk (test|file|package)" default:"test" enum:"test,file,package"`
+	Strategy       string        `help:"How tests are assigned to chunks (auto|contiguous|round-robin|greedy|balanced|hash|package)" default:"auto"`
+	Weights        []float64     `help:"Relative capacity of each chunk, e.g. 2,2,1,1 gives the first two chunks twice the work of the others" env:"GOTESTCHUNK_WEIGHTS"`
+	Rules          string        `help:"Read rules that pin, isolate or group tests from this JSON file" default:""`
+	SplitSubtests  time.Duration `help:"Split tests estimated to take longer than this into their subtests from timing data, so they can run in different chunks (0 to disable)" default:"0"`
+	ReadTiming     string        `help:"Read test timing information from files matching this glob pattern" default:""`
+	Estimator      string        `help:"How to estimate the duration of tests without timing data (default|package-median|suite-median|static)" default:"default"`
+	Args           []string      `arg:"" optional:"" passthrough:"" help:"Packages to plan, followed by optional -- and go test arguments, of which build flags such as -tags are used for discovery"`
+}
+
+func (cmd *PlanCmd) Validate() error {
+	if cmd.Chunks < 1 {
+		return fmt.Errorf("chunks must be >= 1")
+	}
+	if cmd.TargetDuration < 0 {
+		return fmt.Errorf("target duration must be positive")
+	}
+	if cmd.TargetDuration > 0 {
+		if cmd.Chunks > 1 {
+			return fmt.Errorf("chunks and target duration can't both be set")
+		}
+		if cmd.ReadTiming == "" {
+			return fmt.Errorf("target duration requires timing data from --read-timing")
+		}
+		if len(cmd.Weights) > 0 {
+			return fmt.Errorf("weights and target duration can't both be set")
+		}
+	} else if err := validateWeights(cmd.Weights, cmd.Chunks); err != nil {
+		return err
+	}
+	if _, err := testlist.ParseKinds(cmd.Kinds); err != nil {
+		return err
+	}
+	if _, err := testlist.NewEstimator(cmd.Estimator, nil); err != nil {
+		return err
+	}
+	if _, err := testlist.LookupStrategy(cmd.Strategy); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cmd *PlanCmd) Run(logger *zerolog.Logger) error {
+	packages, testArgs := splitArgs(cmd.Args)
+
+	kinds, err := testlist.ParseKinds(cmd.Kinds)
+	if err != nil {
+		return err
+	}
+
+	lister := &testlist.Lister{
+		Workers:    cmd.Workers,
+		Discovery:  testlist.Discovery(cmd.Discovery),
+		BuildFlags: testlist.BuildFlags(testArgs),
+		Kinds:      kinds,
+	}
+
+	tests, err := lister.List(packages...)
+	if err != nil {
+		return fmt.Errorf("error listing tests: %w", err)
+	}
+
+	logger.Debug().
+		Int("tests", len(tests)).
+		Msg("Found tests")
+
+	chunker, err := newChunker(logger, chunkerOptions{
+		Granularity:   cmd.Granularity,
+		Strategy:      cmd.Strategy,
+		Estimator:     cmd.Estimator,
+		ReadTiming:    cmd.ReadTiming,
+		Weights:       cmd.Weights,
+		Rules:         cmd.Rules,
+		SplitSubtests: cmd.SplitSubtests,
+	})
+	if err != nil {
+		return err
+	}
+
+	warnUnmatchedRules(logger, chunker.Rules, tests)
+	var chunks [][]testlist.Test
+	if cmd.TargetDuration > 0 {
+		chunks, err = chunker.ChunksWithin(tests, cmd.TargetDuration)
+	} else {
+		chunks, err = chunker.Chunks(tests, cmd.Chunks)
+	}
+	if err != nil {
+		return fmt.Errorf("error chunking tests: %w", err)
+	}
+
+	balance := chunker.Balance(chunks)
+	if chunker.Timings != nil {
+		logBalance(logger, balance)
+	}
+	if cmd.TargetDuration > 0 && balance.Makespan > cmd.TargetDuration {
+		logger.Warn().
+			Str("target", cmd.TargetDuration.String()).
+			Str("makespan", balance.Makespan.Round(time.Millisecond).String()).
+			Msg("Some tests take longer than the target duration on their own, so chunks are balanced against the longest")
+	}
+
+	plan := chunker.Plan(chunks)
+	plan.Strategy = cmd.Strategy
+	plan.Estimator = cmd.Estimator
+	plan.Target = cmd.TargetDuration
+
+	if cmd.Format == "chunks" {
+		fmt.Println(len(plan.Chunks))
+	} else if cmd.Output == "" {
+		return plan.Write(os.Stdout)
+	}
+	if cmd.Output == "" {
+		return nil
+	}
+
+	f, err := os.Create(cmd.Output)
+	if err != nil {
+		return fmt.Errorf("error creating plan file: %w", err)
+	}
+	if err := plan.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing plan file: %w", err)
+	}
+
+	logger.Info().
+		Str("file", cmd.Output).
+		Int("chunks", len(plan.Chunks)).
+		Int("tests", len(tests)).
+		Msg("Wrote test plan")
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/lox/gotestchunk/pkg/timing"
+	"github.com/rs/zerolog"
+)
+
+func TestPlanCmd_Run(t *testing.T) {
+	testlist.TestRunWithModuleRoot(t, "plan then test", func(t *testing.T) {
+		logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.DebugLevel)
+		file := filepath.Join(t.TempDir(), "plan.json")
+
+		cmd := &PlanCmd{
+			Chunks: 2,
+			Output: file,
+			Args:   []string{"./pkg/example/..."},
+		}
+		if err := cmd.Run(&logger); err != nil {
+			t.Fatalf("PlanCmd.Run() error = %v", err)
+		}
+
+		plan, err := testlist.LoadPlan(file)
+		if err != nil {
+			t.Fatalf("LoadPlan() error = %v", err)
+		}
+		if len(plan.Chunks) != 2 {
+			t.Fatalf("plan has %d chunks, want 2", len(plan.Chunks))
+		}
+
+		// The plan must match what each shard would have computed itself
+		tests, err := testlist.List("./pkg/example/...")
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		chunks := testlist.Chunks(tests, 2)
+		for i, chunk := range plan.Chunks {
+			if !reflect.DeepEqual(chunk.Tests, chunks[i]) {
+				t.Errorf("plan chunk %d = %v, want %v", i+1, chunk.Tests, chunks[i])
+			}
+		}
+
+		for _, tt := range []struct {
+			name      string
+			cmd       *TestCmd
+			wantError bool
+		}{
+			{
+				name: "first chunk",
+				cmd:  &TestCmd{Chunks: 1, Chunk: 1, Plan: file},
+			},
+			{
+				name: "second chunk with matching total",
+				cmd:  &TestCmd{Chunks: 2, Chunk: 2, Plan: file},
+			},
+			{
+				name:      "mismatched total",
+				cmd:       &TestCmd{Chunks: 3, Chunk: 1, Plan: file},
+				wantError: true,
+			},
+			{
+				name:      "chunk out of bounds",
+				cmd:       &TestCmd{Chunks: 1, Chunk: 3, Plan: file},
+				wantError: true,
+			},
+			{
+				name:      "missing plan",
+				cmd:       &TestCmd{Chunks: 1, Chunk: 1, Plan: filepath.Join(t.TempDir(), "missing.json")},
+				wantError: true,
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				err := tt.cmd.Run(&logger)
+				if (err != nil) != tt.wantError {
+					t.Errorf("TestCmd.Run() error = %v, wantError %v", err, tt.wantError)
+				}
+			})
+		}
+	})
+}
+
+func TestPlanCmd_TargetDuration(t *testing.T) {
+	testlist.TestRunWithModuleRoot(t, "chunks for target duration", func(t *testing.T) {
+		logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.DebugLevel)
+		dir := t.TempDir()
+
+		var timings []timing.Test
+		for _, name := range []string{"TestSimple", "TestParallel", "TestTableDriven", "TestWithSetup"} {
+			timings = append(timings, timing.Test{Package: "pkg/example", Test: name, Time: time.Minute})
+		}
+		for _, name := range []string{"TestMath", "TestDivideErrors"} {
+			timings = append(timings, timing.Test{Package: "pkg/example/sub", Test: name, Time: time.Minute})
+		}
+		if err := timing.WriteToFile(timings, filepath.Join(dir, "timing.json")); err != nil {
+			t.Fatalf("WriteToFile() error = %v", err)
+		}
+
+		file := filepath.Join(dir, "plan.json")
+		cmd := &PlanCmd{
+			Chunks:         1,
+			TargetDuration: 2 * time.Minute,
+			ReadTiming:     filepath.Join(dir, "timing.json"),
+			Output:         file,
+			Format:         "chunks",
+			Estimator:      "suite-median",
+			Args:           []string{"./pkg/example/..."},
+		}
+		if err := cmd.Validate(); err != nil {
+			t.Fatalf("PlanCmd.Validate() error = %v", err)
+		}
+
+		output, err := captureOutput(func() error {
+			return cmd.Run(&logger)
+		})
+		if err != nil {
+			t.Fatalf("PlanCmd.Run() error = %v", err)
+		}
+		if got := strings.TrimSpace(output); got != "3" {
+			t.Errorf("PlanCmd.Run() printed %q, want 3", got)
+		}
+
+		plan, err := testlist.LoadPlan(file)
+		if err != nil {
+			t.Fatalf("LoadPlan() error = %v", err)
+		}
+		if plan.Estimator != "suite-median" {
+			t.Errorf("plan estimator = %q, want suite-median", plan.Estimator)
+		}
+		if plan.Target != 2*time.Minute {
+			t.Errorf("plan target = %v, want 2m", plan.Target)
+		}
+		for _, chunk := range plan.Chunks {
+			if chunk.Duration > 2*time.Minute {
+				t.Errorf("chunk %d duration = %v, over target", chunk.Index, chunk.Duration)
+			}
+		}
+	})
+}
+
+func TestPlanCmd_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		cmd       *PlanCmd
+		wantError bool
+	}{
+		{
+			name: "chunks",
+			cmd:  &PlanCmd{Chunks: 4},
+		},
+		{
+			name: "target duration",
+			cmd:  &PlanCmd{Chunks: 1, TargetDuration: 5 * time.Minute, ReadTiming: "timing-*.json"},
+		},
+		{
+			name:      "target duration without timings",
+			cmd:       &PlanCmd{Chunks: 1, TargetDuration: 5 * time.Minute},
+			wantError: true,
+		},
+		{
+			name:      "target duration and chunks",
+			cmd:       &PlanCmd{Chunks: 4, TargetDuration: 5 * time.Minute, ReadTiming: "timing-*.json"},
+			wantError: true,
+		},
+		{
+			name:      "invalid chunks",
+			cmd:       &PlanCmd{Chunks: 0},
+			wantError: true,
+		},
+		{
+			name: "weights",
+			cmd:  &PlanCmd{Chunks: 2, Weights: []float64{2, 1}},
+		},
+		{
+			name:      "weights not matching chunks",
+			cmd:       &PlanCmd{Chunks: 4, Weights: []float64{2, 1}},
+			wantError: true,
+		},
+		{
+			name:      "weights and target duration",
+			cmd:       &PlanCmd{Chunks: 1, TargetDuration: 5 * time.Minute, ReadTiming: "timing-*.json", Weights: []float64{1}},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cmd.Validate()
+			if (err != nil) != tt.wantError {
+				t.Errorf("PlanCmd.Validate() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
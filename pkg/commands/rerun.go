@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/lox/gotestchunk/pkg/testrunner"
+	"github.com/rs/zerolog"
+)
+
+// testFailures records the top-level tests and packages that fail in a run
+type testFailures struct {
+	tests    map[string]bool // Failed tests, keyed by package and test name
+	packages map[string]bool // Failed packages
+}
+
+// HandleEvent processes a test event
+func (f *testFailures) HandleEvent(event testrunner.TestEvent) error {
+	if event.Action != "fail" || strings.Contains(event.Test, "/") {
+		return nil
+	}
+	if f.tests == nil {
+		f.tests = make(map[string]bool)
+		f.packages = make(map[string]bool)
+	}
+	if event.Test == "" {
+		f.packages[event.Package] = true
+	} else {
+		f.tests[event.Package+"."+event.Test] = true
+	}
+	return nil
+}
+
+// rerun returns the tests to run again for the failures, and the sorted
+// packages and tests whose failures can't be fixed by rerunning tests, such
+// as a package that doesn't build or a test that wasn't discovered. Failures
+// are matched to tests by top-level name, so every part of a test split by
+// subtest is rerun.
+func (f *testFailures) rerun(moduleName string, tests []testlist.Test) (rerun []testlist.Test, blocked []string) {
+	// A package that fails without a failing test has a problem that isn't
+	// down to any one test
+	explained := make(map[string]bool)
+	for key := range f.tests {
+		explained[key[:strings.LastIndex(key, ".")]] = true
+	}
+	for pkg := range f.packages {
+		if !explained[pkg] {
+			blocked = append(blocked, pkg)
+		}
+	}
+
+	matched := make(map[string]bool)
+	for _, test := range tests {
+		pkg := test.Package
+		if pkg != moduleName {
+			pkg = moduleName + "/" + pkg
+		}
+		name, _, _ := strings.Cut(test.Name, "/")
+		if key := pkg + "." + name; f.tests[key] {
+			rerun = append(rerun, test)
+			matched[key] = true
+		}
+	}
+	for key := range f.tests {
+		if !matched[key] {
+			blocked = append(blocked, key)
+		}
+	}
+	sort.Strings(blocked)
+	return rerun, blocked
+}
+
+// rerunSummary records the outcome of every top-level test that fails in
+// any attempt, and writes them to a file when flushed
+type rerunSummary struct {
+	Filename string
+	Logger   *zerolog.Logger
+
+	results map[string]*rerunResult
+}
+
+// rerunResult is the outcome of a test that failed at least once
+type rerunResult struct {
+	Package  string `json:"package"`
+	Test     string `json:"test"`
+	Attempts int    `json:"attempts"` // Number of times the test ran
+
+	failed bool // Whether the test failed in its latest attempt
+}
+
+// HandleEvent processes a test event
+func (s *rerunSummary) HandleEvent(event testrunner.TestEvent) error {
+	if event.Test == "" || strings.Contains(event.Test, "/") {
+		return nil
+	}
+	if event.Action != "pass" && event.Action != "fail" {
+		return nil
+	}
+
+	key := event.Package + "." + event.Test
+	attempt := max(event.Attempt, 1)
+	result, ok := s.results[key]
+	if !ok {
+		if event.Action != "fail" {
+			return nil
+		}
+		if s.results == nil {
+			s.results = make(map[string]*rerunResult)
+		}
+		result = &rerunResult{Package: event.Package, Test: event.Test}
+		s.results[key] = result
+	}
+
+	// A test split by subtest runs in several invocations, any of which
+	// can fail the attempt
+	if attempt > result.Attempts {
+		result.Attempts = attempt
+		result.failed = false
+	}
+	result.failed = result.failed || event.Action == "fail"
+	return nil
+}
+
+// Flush writes the summary, which matters most when tests failed or the run
+// was interrupted
+func (s *rerunSummary) Flush() error {
+	if err := s.write(s.Filename); err != nil {
+		return err
+	}
+	s.Logger.Info().
+		Str("file", s.Filename).
+		Msg("Wrote rerun summary")
+	return nil
+}
+
+// write writes the flaky tests, which passed on a rerun, and the failed
+// tests, which failed every attempt, to a JSON file
+func (s *rerunSummary) write(filename string) error {
+	summary := struct {
+		Flaky  []*rerunResult `json:"flaky"`
+		Failed []*rerunResult `json:"failed"`
+	}{
+		Flaky:  []*rerunResult{},
+		Failed: []*rerunResult{},
+	}
+
+	keys := make([]string, 0, len(s.results))
+	for key := range s.results {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if result := s.results[key]; result.failed {
+			summary.Failed = append(summary.Failed, result)
+		} else {
+			summary.Flaky = append(summary.Flaky, result)
+		}
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling rerun summary: %w", err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("error writing rerun summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/coordinator"
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/rs/zerolog"
+)
+
+type ServeCmd struct {
+	Listen       string        `help:"Address to listen on, e.g. localhost:8080 or unix:/tmp/gotestchunk.sock" default:"localhost:8080"`
+	LeaseTimeout time.Duration `help:"Give a batch to another worker if its worker doesn't renew the lease within this long" default:"1m"`
+	Linger       time.Duration `help:"Keep serving for this long after every batch completes, so waiting workers learn they are done" default:"5s"`
+	Workers      int           `help:"Number of packages to discover tests in concurrently (defaults to GOMAXPROCS)" default:"0"`
+	Discovery    string        `help:"How to discover tests (compile|static)" default:"compile" enum:"compile,static"`
+	Kinds        []string      `help:"Kinds of tests to serve (test|example|benchmark|fuzz)" default:"test"`
+	Granularity  string        `help:"Smallest group of tests leased to a worker at once (test|file|package)" default:"package" enum:"test,file,package"`
+	ReadTiming   string        `help:"Read test timing information from files matching this glob pattern, to lease the longest tests first" default:""`
+	Estimator    string        `help:"How to estimate the duration of tests without timing data (default|package-median|suite-median|static)" default:"default"`
+	Args         []string      `arg:"" optional:"" passthrough:"" help:"Packages to serve, followed by optional -- and go test arguments, of which build flags such as -tags are used for discovery"`
+}
+
+func (cmd *ServeCmd) Validate() error {
+	if _, err := testlist.ParseKinds(cmd.Kinds); err != nil {
+		return err
+	}
+	if _, err := testlist.NewEstimator(cmd.Estimator, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cmd *ServeCmd) Run(logger *zerolog.Logger) error {
+	packages, testArgs := splitArgs(cmd.Args)
+
+	kinds, err := testlist.ParseKinds(cmd.Kinds)
+	if err != nil {
+		return err
+	}
+
+	lister := &testlist.Lister{
+		Workers:    cmd.Workers,
+		Discovery:  testlist.Discovery(cmd.Discovery),
+		BuildFlags: testlist.BuildFlags(testArgs),
+		Kinds:      kinds,
+	}
+
+	tests, err := lister.List(packages...)
+	if err != nil {
+		return fmt.Errorf("error listing tests: %w", err)
+	}
+	if len(tests) == 0 {
+		return fmt.Errorf("no tests found")
+	}
+
+	chunker, err := newChunker(logger, chunkerOptions{
+		Granularity: cmd.Granularity,
+		Estimator:   cmd.Estimator,
+		ReadTiming:  cmd.ReadTiming,
+	})
+	if err != nil {
+		return err
+	}
+
+	batches, err := coordinator.NewBatches(tests, testlist.Granularity(cmd.Granularity), chunker)
+	if err != nil {
+		return err
+	}
+
+	queue := coordinator.NewQueue(batches, cmd.LeaseTimeout)
+
+	listener, err := coordinator.Listen(cmd.Listen)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", cmd.Listen, err)
+	}
+
+	server := &http.Server{
+		Handler: &coordinator.Server{Queue: queue, Logger: logger},
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	logger.Info().
+		Str("listen", cmd.Listen).
+		Int("tests", len(tests)).
+		Int("batches", len(batches)).
+		Msg("Serving tests to workers")
+
+	select {
+	case <-queue.Done():
+	case err := <-serveErr:
+		return fmt.Errorf("error serving tests: %w", err)
+	}
+
+	// Workers waiting on leased batches poll for more work, so give them a
+	// chance to hear that there is none before shutting down
+	time.Sleep(cmd.Linger)
+	if err := server.Shutdown(context.Background()); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error shutting down: %w", err)
+	}
+
+	status := queue.Status()
+	logger.Info().
+		Int("batches", status.Completed).
+		Int("requeued", status.Requeued).
+		Strs("failed", status.Failed).
+		Msg("All tests complete")
+
+	for _, msg := range status.Errors {
+		logger.Error().Msg(msg)
+	}
+	return statusError(status)
+}
+
+// statusError returns an error if any tests failed or batches errored, with
+// the exit code for test failures when only tests failed
+func statusError(status coordinator.Status) error {
+	if len(status.Errors) > 0 {
+		return fmt.Errorf("%d tests failed and %d batches errored", len(status.Failed), len(status.Errors))
+	}
+	if len(status.Failed) > 0 {
+		return &ExitCodeError{Code: ExitTestFailure, Err: fmt.Errorf("%d tests failed", len(status.Failed))}
+	}
+	return nil
+}
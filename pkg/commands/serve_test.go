@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/rs/zerolog"
+)
+
+func TestServeCmd_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		packages   []string
+		rerunFails int
+	}{
+		{
+			name:     "serve to two workers",
+			packages: []string{"./pkg/example/..."},
+		},
+		{
+			name:       "flaky test passes on rerun",
+			packages:   []string{"./pkg/commands/testdata/flaky"},
+			rerunFails: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		testlist.TestRunWithModuleRoot(t, tt.name, func(t *testing.T) {
+			testlist.SetFlakyEnv(t, false)
+
+			logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).Level(zerolog.DebugLevel)
+			socket := filepath.Join(t.TempDir(), "coordinator.sock")
+			addr := "unix:" + socket
+
+			serve := &ServeCmd{
+				Listen:       addr,
+				LeaseTimeout: time.Minute,
+				Linger:       2 * time.Second,
+				Granularity:  "package",
+				Args:         tt.packages,
+			}
+			served := make(chan error, 1)
+			go func() {
+				served <- serve.Run(&logger)
+			}()
+
+			// Wait for the coordinator to finish discovery and start listening
+			deadline := time.Now().Add(time.Minute)
+			for {
+				if _, err := os.Stat(socket); err == nil {
+					break
+				}
+				select {
+				case err := <-served:
+					t.Fatalf("ServeCmd.Run() returned early: %v", err)
+				default:
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("coordinator did not start listening")
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			var wg sync.WaitGroup
+			workerErrs := make([]error, 2)
+			for i := range workerErrs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					worker := &TestCmd{Chunks: 1, Chunk: 1, Coordinator: addr, RerunFails: tt.rerunFails}
+					workerErrs[i] = worker.Run(&logger)
+				}(i)
+			}
+			wg.Wait()
+
+			for i, err := range workerErrs {
+				if err != nil {
+					t.Errorf("worker %d error = %v", i, err)
+				}
+			}
+			if err := <-served; err != nil {
+				t.Errorf("ServeCmd.Run() error = %v", err)
+			}
+		})
+	}
+}
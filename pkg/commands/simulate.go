@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/rs/zerolog"
+)
+
+type SimulateCmd struct {
+	Chunks        int           `help:"Number of chunks to split tests into" default:"2"`
+	Strategy      []string      `help:"Strategies to compare (auto|contiguous|round-robin|greedy|balanced|hash|package)" default:"auto"`
+	Weights       []float64     `help:"Relative capacity of each chunk, e.g. 2,2,1,1 gives the first two chunks twice the work of the others" env:"GOTESTCHUNK_WEIGHTS"`
+	Rules         string        `help:"Read rules that pin, isolate or group tests from this JSON file" default:""`
+	SplitSubtests time.Duration `help:"Split tests estimated to take longer than this into their subtests from timing data, so they can run in different chunks (0 to disable)" default:"0"`
+	Format        string        `help:"Output format (text|json)" default:"text" enum:"text,json"`
+	Workers       int           `help:"Number of packages to discover tests in concurrently (defaults to GOMAXPROCS)" default:"0"`
+	Discovery     string        `help:"How to discover tests (compile|static)" default:"compile" enum:"compile,static"`
+	Kinds         []string      `help:"Kinds of tests to simulate (test|example|benchmark|fuzz)" default:"test"`
+	Granularity   string        `help:"Smallest group of tests assigned to a chunk (test|file|package)" default:"test" enum:"test,file,package"`
+	ReadTiming    string        `help:"Read test timing information from files matching this glob pattern" default:""`
+	Estimator     string        `help:"How to estimate the duration of tests without timing data (default|package-median|suite-median|static)" default:"default"`
+	Args          []string      `arg:"" optional:"" passthrough:"" help:"Packages to simulate, followed by optional -- and go test arguments, of which build flags such as -tags are used for discovery"`
+}
+
+func (cmd *SimulateCmd) Validate() error {
+	if cmd.Chunks < 1 {
+		return fmt.Errorf("chunks must be >= 1")
+	}
+	if err := validateWeights(cmd.Weights, cmd.Chunks); err != nil {
+		return err
+	}
+	if _, err := testlist.ParseKinds(cmd.Kinds); err != nil {
+		return err
+	}
+	if _, err := testlist.NewEstimator(cmd.Estimator, nil); err != nil {
+		return err
+	}
+	for _, name := range cmd.Strategy {
+		if _, err := testlist.LookupStrategy(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cmd *SimulateCmd) Run(logger *zerolog.Logger) error {
+	packages, testArgs := splitArgs(cmd.Args)
+
+	kinds, err := testlist.ParseKinds(cmd.Kinds)
+	if err != nil {
+		return err
+	}
+
+	lister := &testlist.Lister{
+		Workers:    cmd.Workers,
+		Discovery:  testlist.Discovery(cmd.Discovery),
+		BuildFlags: testlist.BuildFlags(testArgs),
+		Kinds:      kinds,
+	}
+
+	tests, err := lister.List(packages...)
+	if err != nil {
+		return fmt.Errorf("error listing tests: %w", err)
+	}
+
+	logger.Debug().
+		Int("tests", len(tests)).
+		Msg("Found tests")
+
+	// Every strategy is simulated with the same chunker, so they only
+	// differ by strategy
+	chunker, err := newChunker(logger, chunkerOptions{
+		Granularity:   cmd.Granularity,
+		Estimator:     cmd.Estimator,
+		ReadTiming:    cmd.ReadTiming,
+		Weights:       cmd.Weights,
+		Rules:         cmd.Rules,
+		SplitSubtests: cmd.SplitSubtests,
+	})
+	if err != nil {
+		return err
+	}
+	warnUnmatchedRules(logger, chunker.Rules, tests)
+
+	strategies := cmd.Strategy
+	if len(strategies) == 0 {
+		strategies = []string{"auto"}
+	}
+
+	reports := make([]testlist.Report, len(strategies))
+	for i, name := range strategies {
+		chunker.Strategy, err = testlist.LookupStrategy(name)
+		if err != nil {
+			return err
+		}
+
+		chunks, err := chunker.Chunks(tests, cmd.Chunks)
+		if err != nil {
+			return fmt.Errorf("error chunking tests with %s: %w", name, err)
+		}
+
+		reports[i] = chunker.Report(chunks)
+		reports[i].Strategy = name
+		reports[i].Estimator = cmd.Estimator
+	}
+
+	if cmd.Format == "json" {
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling report: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := writeReport(os.Stdout, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeReport writes a report as a table of chunks followed by a summary
+func writeReport(w io.Writer, report testlist.Report) error {
+	fmt.Fprintf(w, "Strategy: %s\n", report.Strategy)
+	if report.Estimator != "" {
+		fmt.Fprintf(w, "Estimator: %s\n", report.Estimator)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHUNK\tTESTS\tPACKAGES\tDURATION\tUNTIMED")
+	for _, chunk := range report.Chunks {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%d\n",
+			chunk.Index, chunk.Tests, chunk.Packages, chunk.Duration.Round(time.Millisecond), chunk.Untimed)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+
+	_, err := fmt.Fprintf(w, "Makespan: %s, imbalance: %.1f%%, untimed tests: %d\n",
+		report.Makespan.Round(time.Millisecond), report.Imbalance*100, report.Untimed)
+	return err
+}
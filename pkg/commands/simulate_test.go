@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/rs/zerolog"
+)
+
+func TestSimulateCmd_Run(t *testing.T) {
+	testlist.TestRunWithModuleRoot(t, "text", func(t *testing.T) {
+		logger := zerolog.New(zerolog.NewTestWriter(t))
+		cmd := &SimulateCmd{
+			Chunks:   3,
+			Strategy: []string{"contiguous", "package"},
+			Format:   "text",
+			Args:     []string{"./pkg/example/..."},
+		}
+
+		output, err := captureOutput(func() error {
+			return cmd.Run(&logger)
+		})
+		if err != nil {
+			t.Fatalf("SimulateCmd.Run() error = %v", err)
+		}
+
+		want := `Strategy: contiguous
+CHUNK  TESTS  PACKAGES  DURATION  UNTIMED
+1      2      1         2s        2
+2      2      1         2s        2
+3      2      1         2s        2
+Makespan: 2s, imbalance: 0.0%, untimed tests: 6
+
+Strategy: package
+CHUNK  TESTS  PACKAGES  DURATION  UNTIMED
+1      4      1         4s        4
+2      2      1         2s        2
+3      0      0         0s        0
+Makespan: 4s, imbalance: 100.0%, untimed tests: 6
+`
+		if output != want {
+			t.Errorf("SimulateCmd.Run() output:\n%s\nwant:\n%s", output, want)
+		}
+	})
+
+	testlist.TestRunWithModuleRoot(t, "json", func(t *testing.T) {
+		logger := zerolog.New(zerolog.NewTestWriter(t))
+		cmd := &SimulateCmd{
+			Chunks:   2,
+			Strategy: []string{"auto"},
+			Format:   "json",
+			Args:     []string{"./pkg/example/..."},
+		}
+
+		output, err := captureOutput(func() error {
+			return cmd.Run(&logger)
+		})
+		if err != nil {
+			t.Fatalf("SimulateCmd.Run() error = %v", err)
+		}
+
+		var reports []testlist.Report
+		if err := json.NewDecoder(strings.NewReader(output)).Decode(&reports); err != nil {
+			t.Fatalf("invalid JSON output: %v\n%s", err, output)
+		}
+		if len(reports) != 1 || len(reports[0].Chunks) != 2 {
+			t.Fatalf("SimulateCmd.Run() reports = %+v", reports)
+		}
+		if reports[0].Strategy != "auto" || reports[0].Untimed != 6 {
+			t.Errorf("SimulateCmd.Run() report = %+v", reports[0])
+		}
+	})
+}
+
+func TestSimulateCmd_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		cmd       *SimulateCmd
+		wantError bool
+	}{
+		{
+			name: "valid",
+			cmd:  &SimulateCmd{Chunks: 4, Strategy: []string{"greedy", "balanced"}},
+		},
+		{
+			name:      "unknown strategy",
+			cmd:       &SimulateCmd{Chunks: 4, Strategy: []string{"greedy", "magic"}},
+			wantError: true,
+		},
+		{
+			name:      "invalid chunks",
+			cmd:       &SimulateCmd{Chunks: 0},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cmd.Validate()
+			if (err != nil) != tt.wantError {
+				t.Errorf("SimulateCmd.Validate() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
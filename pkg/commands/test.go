@@ -1,28 +1,45 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strconv"
+	"syscall"
 	"time"
 
-	"github.com/bmatcuk/doublestar/v4"
 	"github.com/lox/gotestchunk/pkg/ciparallel"
 	"github.com/lox/gotestchunk/pkg/testlist"
 	"github.com/lox/gotestchunk/pkg/testrunner"
-	"github.com/lox/gotestchunk/pkg/timing"
 	"github.com/rs/zerolog"
 )
 
 type TestCmd struct {
-	Chunks      int      `help:"Number of chunks to split tests into (defaults to CI value if available)" default:"1"`
-	Chunk       int      `help:"Which chunk to output (1-based, defaults to CI value if available)" default:"1"`
-	Count       int      `help:"Number of times to run each test" default:"0"`
-	Verbose     bool     `short:"v" help:"Verbose output" default:"false"`
-	Args        []string `arg:"" optional:"" passthrough:"" help:"Packages to test, followed by optional -- and test arguments"`
-	WriteTiming string   `help:"Write test timing information to this JSON file" default:""`
-	ReadTiming  string   `help:"Read test timing information from files matching this glob pattern" default:""`
+	Chunks           int           `help:"Number of chunks to split tests into (defaults to CI value if available)" default:"1"`
+	Chunk            int           `help:"Which chunk to output (1-based, defaults to CI value if available)" default:"1"`
+	Count            int           `help:"Number of times to run each test" default:"0"`
+	Verbose          bool          `short:"v" help:"Verbose output" default:"false"`
+	Args             []string      `arg:"" optional:"" passthrough:"" help:"Packages to test, followed by optional -- and test arguments"`
+	WriteTiming      string        `help:"Write test timing information to this JSON file" default:""`
+	ReadTiming       string        `help:"Read test timing information from files matching this glob pattern" default:""`
+	Estimator        string        `help:"How to estimate the duration of tests without timing data (default|package-median|suite-median|static)" default:"default"`
+	Workers          int           `help:"Number of packages to discover tests in concurrently (defaults to GOMAXPROCS)" default:"0"`
+	Discovery        string        `help:"How to discover tests (compile|static)" default:"compile" enum:"compile,static"`
+	Kinds            []string      `help:"Kinds of tests to run (test|example|benchmark|fuzz)" default:"test"`
+	FuzzTime         string        `help:"Fuzz each fuzz target for this long with -fuzz, rather than only running its seed corpus" default:""`
+	MaxPatternLength int           `help:"Split tests across multiple go test invocations when a -run pattern would be longer than this (0 for no limit)" default:"16384"`
+	Granularity      string        `help:"Smallest group of tests assigned to a chunk (test|file|package)" default:"test" enum:"test,file,package"`
+	Strategy         string        `help:"How tests are assigned to chunks (auto|contiguous|round-robin|greedy|balanced|hash|package)" default:"auto"`
+	Weights          []float64     `help:"Relative capacity of each chunk, e.g. 2,2,1,1 gives the first two chunks twice the work of the others" env:"GOTESTCHUNK_WEIGHTS"`
+	Rules            string        `help:"Read rules that pin, isolate or group tests from this JSON file" default:""`
+	SplitSubtests    time.Duration `help:"Split tests estimated to take longer than this into their subtests from timing data, so they can run in different chunks (0 to disable)" default:"0"`
+	RerunFails       int           `help:"Rerun failed tests up to this many times, only failing if a test fails every attempt" default:"0"`
+	RerunSummary     string        `help:"Write the flaky and failed tests from rerunning failed tests to this JSON file" default:""`
+	Coordinator      string        `help:"Run batches of tests leased from a coordinator started with the serve command, rather than a fixed chunk" default:""`
+	Plan             string        `help:"Run this chunk of a plan written by the plan command, rather than discovering and chunking tests" default:""`
+	GracePeriod      time.Duration `help:"How long go test has to exit after an interrupt or termination signal is forwarded to it before it is killed" default:"10s"`
+	ChunkTimeout     time.Duration `help:"Send hung tests SIGQUIT a grace period before this long, failing them with a goroutine dump, and kill them at the deadline (0 for no limit)" default:"0"`
 }
 
 func (cmd *TestCmd) Validate() error {
@@ -35,107 +52,164 @@ func (cmd *TestCmd) Validate() error {
 	if cmd.Chunks < 1 {
 		return fmt.Errorf("chunks must be >= 1")
 	}
-	if cmd.Chunk < 1 || cmd.Chunk > cmd.Chunks {
+	// With a plan, the number of chunks comes from the plan file
+	if cmd.Chunk < 1 || (cmd.Plan == "" && cmd.Chunk > cmd.Chunks) {
 		return fmt.Errorf("chunk must be between 1 and chunks")
 	}
+	if err := validateWeights(cmd.Weights, cmd.Chunks); err != nil {
+		return err
+	}
+	if cmd.RerunFails < 0 {
+		return fmt.Errorf("rerun fails must be >= 0")
+	}
+	if cmd.ChunkTimeout < 0 {
+		return fmt.Errorf("chunk timeout must be >= 0")
+	}
+	if _, err := testlist.ParseKinds(cmd.Kinds); err != nil {
+		return err
+	}
+	if _, err := testlist.NewEstimator(cmd.Estimator, nil); err != nil {
+		return err
+	}
+	if _, err := testlist.LookupStrategy(cmd.Strategy); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (cmd *TestCmd) Run(logger *zerolog.Logger) error {
+	// Signals are forwarded to go test rather than stopping gotestchunk, so
+	// test binaries aren't orphaned and results so far are written
+	ctx, stop := testrunner.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return cmd.run(ctx, logger)
+}
+
+// chunkTimeoutError is the cause of the context cancelled by
+// --chunk-timeout, which has go test sent SIGQUIT
+type chunkTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *chunkTimeoutError) Error() string {
+	return fmt.Sprintf("chunk timeout of %v reached", e.timeout)
+}
+
+func (e *chunkTimeoutError) Unwrap() error {
+	return &testrunner.SignalError{Signal: syscall.SIGQUIT}
+}
+
+// gracePeriod returns how long go test has to exit after being interrupted
+// before it is killed. It's cut to half of any chunk timeout, so hung tests
+// are sent SIGQUIT with time to report and still killed by the deadline.
+func (cmd *TestCmd) gracePeriod() time.Duration {
+	grace := cmd.GracePeriod
+	if grace <= 0 {
+		grace = testrunner.DefaultGracePeriod
+	}
+	if cmd.ChunkTimeout > 0 && grace >= cmd.ChunkTimeout {
+		grace = max(cmd.ChunkTimeout/2, time.Nanosecond)
+	}
+	return grace
+}
+
+// run runs the tests until they finish or ctx is done
+func (cmd *TestCmd) run(ctx context.Context, logger *zerolog.Logger) error {
 	logger.Debug().
 		Strs("args", cmd.Args).
 		Msg("Running test command")
 
-	// Split Args into packages and test args at --
-	var packages []string
-	var testArgs []string
-
-	foundSeparator := false
-	for i, arg := range cmd.Args {
-		if arg == "--" {
-			packages = cmd.Args[:i]
-			testArgs = cmd.Args[i+1:]
-			foundSeparator = true
-			break
-		}
+	// Hung tests are sent SIGQUIT early enough for go test to report them
+	// before being killed at the deadline
+	interrupted := ctx
+	grace := cmd.gracePeriod()
+	if cmd.ChunkTimeout > 0 {
+		quit := cmd.ChunkTimeout - grace
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		timer := time.AfterFunc(quit, func() {
+			logger.Warn().
+				Str("timeout", cmd.ChunkTimeout.String()).
+				Msg("Chunk timeout reached, sending SIGQUIT to hung tests")
+			cancel(&chunkTimeoutError{timeout: cmd.ChunkTimeout})
+		})
+		defer timer.Stop()
 	}
 
-	// If no -- found, all args are packages
-	if !foundSeparator {
-		packages = cmd.Args
-	}
-	if len(packages) == 0 {
-		packages = []string{"./..."}
-	}
+	packages, testArgs := splitArgs(cmd.Args)
 
 	logger.Debug().
 		Strs("packages", packages).
 		Strs("testArgs", testArgs).
 		Msg("Split arguments")
 
-	// Get all tests
-	tests, listErr := testlist.List(packages...)
-	if listErr != nil {
-		return fmt.Errorf("error listing tests: %w", listErr)
+	// Build go test command args
+	goTestArgs := []string{"-json"}
+	if cmd.Verbose {
+		goTestArgs = append(goTestArgs, "-v")
+	}
+	if cmd.Count > 0 {
+		goTestArgs = append(goTestArgs, "-count="+strconv.Itoa(cmd.Count))
 	}
 
-	logger.Debug().
-		Int("tests", len(tests)).
-		Msg("Found tests")
-
-	// Load timing data if glob pattern provided
-	var timings map[string]time.Duration
-	if cmd.ReadTiming != "" {
-		// Find all matching files
-		pattern := cmd.ReadTiming
-		if !filepath.IsAbs(pattern) {
-			var err error
-			pattern, err = filepath.Abs(pattern)
-			if err != nil {
-				return fmt.Errorf("error getting absolute path: %w", err)
-			}
-		}
+	// Add any test args before the test pattern
+	if len(testArgs) > 0 {
+		goTestArgs = append(goTestArgs, testArgs...)
+	}
+	runner := &testrunner.Runner{
+		Logger:      logger,
+		GracePeriod: grace,
+	}
 
-		// Use doublestar to find all matching files
-		fs := os.DirFS(filepath.Dir(pattern))
-		matches, err := doublestar.Glob(fs, filepath.Base(pattern))
-		if err != nil {
-			return fmt.Errorf("error finding timing files: %w", err)
-		}
+	// Handlers that write files are flushed however the run ends
+	if cmd.WriteTiming != "" {
+		runner.AddHandler(&timingFile{Filename: cmd.WriteTiming, Logger: logger})
+	}
+	if cmd.RerunSummary != "" {
+		runner.AddHandler(&rerunSummary{Filename: cmd.RerunSummary, Logger: logger})
+	}
 
-		// Convert matches to full paths
-		files := make([]string, len(matches))
-		for i, match := range matches {
-			files[i] = filepath.Join(filepath.Dir(pattern), match)
-		}
+	var err error
+	if cmd.Coordinator != "" {
+		err = cmd.work(ctx, logger, runner, goTestArgs)
+	} else {
+		err = cmd.runChunk(ctx, logger, runner, goTestArgs, packages, testArgs)
+	}
+	if flushErr := runner.Flush(); flushErr != nil {
+		err = errors.Join(err, flushErr)
+	}
 
-		if len(files) == 0 {
-			logger.Warn().
-				Str("pattern", cmd.ReadTiming).
-				Msg("No timing files found")
-		} else {
-			timings, err = timing.LoadFromFiles(files)
-			if err != nil {
-				return fmt.Errorf("error loading timing data: %w", err)
-			}
-			logger.Debug().
-				Str("pattern", cmd.ReadTiming).
-				Int("files", len(files)).
-				Int("timings", len(timings)).
-				Msg("Loaded test timing information")
+	// Failures after an interrupt are down to the interrupt
+	if err != nil && interrupted.Err() != nil {
+		return interruptedError(err)
+	}
+	if err != nil {
+		results := append(runner.Results(), buildFailures(err)...)
+		writeFailureSummary(os.Stderr, results)
+		var timeoutErr *chunkTimeoutError
+		if errors.As(context.Cause(ctx), &timeoutErr) {
+			return &ExitCodeError{Code: ExitTimeout, Err: err}
 		}
+		return failedError(err, results)
 	}
 
-	// Get tests for this chunk
-	var chunkTests []testlist.Test
-	var chunkErr error
-	if timings != nil {
-		chunkTests, chunkErr = testlist.ChunkByTiming(tests, cmd.Chunk-1, cmd.Chunks, timings)
+	return nil
+}
+
+// runChunk runs the tests in this chunk, either from a plan or by
+// discovering and chunking tests in packages
+func (cmd *TestCmd) runChunk(ctx context.Context, logger *zerolog.Logger, runner *testrunner.Runner, goTestArgs, packages, testArgs []string) error {
+	var tests, chunkTests []testlist.Test
+	var rules *testlist.Rules
+	var err error
+	if cmd.Plan != "" {
+		tests, chunkTests, rules, err = cmd.planTests(logger)
 	} else {
-		chunkTests, chunkErr = testlist.Chunk(tests, cmd.Chunk-1, cmd.Chunks)
+		tests, chunkTests, rules, err = cmd.chunkTests(logger, packages, testArgs)
 	}
-	if chunkErr != nil {
-		return fmt.Errorf("error getting chunk: %w", chunkErr)
+	if err != nil {
+		return err
 	}
 
 	if len(chunkTests) == 0 {
@@ -147,63 +221,177 @@ func (cmd *TestCmd) Run(logger *zerolog.Logger) error {
 		Int("tests", len(chunkTests)).
 		Msg("Found chunk tests")
 
-	// Build go test command args
-	goTestArgs := []string{"-json"}
-	if cmd.Verbose {
-		goTestArgs = append(goTestArgs, "-v")
+	if err := cmd.runTests(ctx, logger, runner, goTestArgs, chunkTests, tests, rules); err != nil {
+		return fmt.Errorf("error running tests: %w", err)
 	}
-	if cmd.Count > 0 {
-		goTestArgs = append(goTestArgs, "-count="+strconv.Itoa(cmd.Count))
+	return nil
+}
+
+// runTests runs tests with as few go test invocations as possible, where
+// all is every test being run across all chunks. Tests that rules mark as
+// exclusive run one at a time after the others. With --rerun-fails, failed
+// tests are run again, and only an error from the last attempt is returned,
+// along with any from failures that couldn't be rerun.
+func (cmd *TestCmd) runTests(ctx context.Context, logger *zerolog.Logger, runner *testrunner.Runner, goTestArgs []string, tests, all []testlist.Test, rules *testlist.Rules) error {
+	opts := testlist.InvocationOptions{
+		FuzzTime:         cmd.FuzzTime,
+		All:              all,
+		MaxPatternLength: cmd.MaxPatternLength,
+		Exclusive:        rules.Exclusive,
 	}
 
-	// Add any test args before the test pattern
-	if len(testArgs) > 0 {
-		goTestArgs = append(goTestArgs, testArgs...)
+	// Record failures for this call only, as workers run many batches
+	failures := &testFailures{}
+	handlers := runner.Handlers
+	runner.Handlers = append(append([]testrunner.EventHandler{}, handlers...), failures)
+	defer func() {
+		runner.Handlers = handlers
+		runner.Attempt = 0
+	}()
+
+	// Failures that can't be rerun still fail the run, whatever the
+	// attempts that rerun the other failures return
+	var blockedErr error
+	err := runInvocations(ctx, logger, runner, goTestArgs, testlist.Invocations(tests, opts))
+	for attempt := 2; err != nil && ctx.Err() == nil && attempt <= cmd.RerunFails+1; attempt++ {
+		moduleName, modErr := testlist.ModuleName()
+		if modErr != nil {
+			return errors.Join(err, modErr)
+		}
+		rerun, blocked := failures.rerun(moduleName, tests)
+		if len(blocked) > 0 {
+			logger.Warn().
+				Strs("failures", blocked).
+				Msg("Not rerunning failures that aren't down to a discovered test")
+			blockedErr = err
+		}
+		if len(rerun) == 0 {
+			break
+		}
+
+		logger.Info().
+			Int("attempt", attempt).
+			Int("tests", len(rerun)).
+			Msg("Rerunning failed tests")
+
+		failures.tests, failures.packages = nil, nil
+		runner.Attempt = attempt
+		err = runInvocations(ctx, logger, runner, goTestArgs, testlist.Invocations(rerun, opts))
+	}
+	if blockedErr != nil && blockedErr != err {
+		err = errors.Join(blockedErr, err)
 	}
+	return err
+}
+
+// runInvocations runs each invocation, carrying on after failures so every
+// test runs, but stopping when ctx is done
+func runInvocations(ctx context.Context, logger *zerolog.Logger, runner *testrunner.Runner, goTestArgs []string, invocations []testlist.Invocation) error {
+	logger.Debug().
+		Int("invocations", len(invocations)).
+		Msg("Planned go test invocations")
+
+	var runErrs []error
+	for _, inv := range invocations {
+		runner.Args = append(append([]string{}, goTestArgs...), inv.Args()...)
+		if err := runner.RunContext(ctx); err != nil {
+			runErrs = append(runErrs, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(runErrs...)
+}
 
-	// Add test pattern
-	pattern, err := testlist.Format(chunkTests, "runPattern")
+// chunkTests discovers tests in packages and returns them along with the
+// tests in this chunk and the rules they were chunked with
+func (cmd *TestCmd) chunkTests(logger *zerolog.Logger, packages, testArgs []string) ([]testlist.Test, []testlist.Test, *testlist.Rules, error) {
+	kinds, err := testlist.ParseKinds(cmd.Kinds)
 	if err != nil {
-		return fmt.Errorf("error formatting test pattern: %w", err)
+		return nil, nil, nil, err
 	}
-	if pattern != "" {
-		goTestArgs = append(goTestArgs, "-run="+pattern)
+
+	// Get all tests
+	lister := &testlist.Lister{
+		Workers:    cmd.Workers,
+		Discovery:  testlist.Discovery(cmd.Discovery),
+		BuildFlags: testlist.BuildFlags(testArgs),
+		Kinds:      kinds,
 	}
 
-	// Add packages
-	for _, pkg := range testlist.Packages(chunkTests) {
-		goTestArgs = append(goTestArgs, "./"+pkg)
+	tests, err := lister.List(packages...)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error listing tests: %w", err)
 	}
 
-	runner := &testrunner.Runner{
-		Args:   goTestArgs,
-		Logger: logger,
+	logger.Debug().
+		Int("tests", len(tests)).
+		Msg("Found tests")
+
+	// Get tests for this chunk
+	chunker, err := newChunker(logger, chunkerOptions{
+		Granularity:   cmd.Granularity,
+		Strategy:      cmd.Strategy,
+		Estimator:     cmd.Estimator,
+		ReadTiming:    cmd.ReadTiming,
+		Weights:       cmd.Weights,
+		Rules:         cmd.Rules,
+		SplitSubtests: cmd.SplitSubtests,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if cmd.Chunk < 1 || cmd.Chunk > cmd.Chunks {
+		return nil, nil, nil, fmt.Errorf("chunk %d out of bounds (total chunks: %d)", cmd.Chunk, cmd.Chunks)
+	}
+	warnUnmatchedRules(logger, chunker.Rules, tests)
+	chunks, err := chunker.Chunks(tests, cmd.Chunks)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error getting chunk: %w", err)
 	}
 
-	// If timing file is requested, we need to capture and parse the output
-	if cmd.WriteTiming != "" {
-		collector := &timing.Collector{}
-		runner.AddHandler(collector)
+	if chunker.Timings != nil {
+		logBalance(logger, chunker.Balance(chunks))
+	}
 
-		// Run tests
-		if err := runner.Run(); err != nil {
-			return fmt.Errorf("error running tests: %w", err)
-		}
+	return tests, chunks[cmd.Chunk-1], chunker.Rules, nil
+}
 
-		// Write timing data to file if we got any results
-		if len(collector.Tests) > 0 {
-			if err := timing.WriteToFile(collector.Tests, cmd.WriteTiming); err != nil {
-				return err
-			}
+// planTests reads the plan file and returns every test in the plan along with
+// the tests in this chunk, without discovering or chunking anything. Rules
+// come from the plan unless --rules is set.
+func (cmd *TestCmd) planTests(logger *zerolog.Logger) ([]testlist.Test, []testlist.Test, *testlist.Rules, error) {
+	plan, err := testlist.LoadPlan(cmd.Plan)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-			logger.Info().
-				Str("file", cmd.WriteTiming).
-				Int("tests", len(collector.Tests)).
-				Msg("Wrote test timing information")
-		}
+	// A shard that expects a different number of chunks would skip or
+	// repeat tests, so only an unset or matching total is accepted
+	if cmd.Chunks > 1 && cmd.Chunks != len(plan.Chunks) {
+		return nil, nil, nil, fmt.Errorf("plan has %d chunks but %d were requested", len(plan.Chunks), cmd.Chunks)
+	}
 
-		return nil
+	chunkTests, err := plan.Chunk(cmd.Chunk)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	logger.Debug().
+		Str("file", cmd.Plan).
+		Int("chunks", len(plan.Chunks)).
+		Msg("Loaded test plan")
+
+	rules := plan.Rules
+	if err := setRulesModule(rules); err != nil {
+		return nil, nil, nil, err
+	}
+	if cmd.Rules != "" {
+		if rules, err = loadRules(cmd.Rules); err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
-	return runner.Run()
+	return plan.Tests(), chunkTests, rules, nil
 }
@@ -1,10 +1,16 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/lox/gotestchunk/pkg/testrunner"
 	"github.com/rs/zerolog"
 )
 
@@ -47,6 +53,24 @@ func TestTestCmd_Run(t *testing.T) {
 				Args:   []string{"./pkg/example/...", "--", "-v", "-count=1"},
 			},
 		},
+		{
+			name: "benchmarks and examples",
+			cmd: &TestCmd{
+				Chunks: 1,
+				Chunk:  1,
+				Kinds:  []string{"benchmark", "example"},
+				Args:   []string{"./pkg/example/...", "--", "-benchtime=1x"},
+			},
+		},
+		{
+			name: "split long patterns",
+			cmd: &TestCmd{
+				Chunks:           1,
+				Chunk:            1,
+				MaxPatternLength: 20,
+				Args:             []string{"./pkg/example/..."},
+			},
+		},
 		{
 			name: "invalid chunk index",
 			cmd: &TestCmd{
@@ -107,6 +131,41 @@ func TestTestCmd_Validate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "unknown kind",
+			cmd: &TestCmd{
+				Chunks: 1,
+				Chunk:  1,
+				Kinds:  []string{"integration"},
+			},
+			wantError: true,
+		},
+		{
+			name: "chunk beyond chunks with plan",
+			cmd: &TestCmd{
+				Chunks: 1,
+				Chunk:  3,
+				Plan:   "plan.json",
+			},
+		},
+		{
+			name: "negative rerun fails",
+			cmd: &TestCmd{
+				Chunks:     1,
+				Chunk:      1,
+				RerunFails: -1,
+			},
+			wantError: true,
+		},
+		{
+			name: "negative chunk timeout",
+			cmd: &TestCmd{
+				Chunks:       1,
+				Chunk:        1,
+				ChunkTimeout: -time.Second,
+			},
+			wantError: true,
+		},
 		{
 			name: "negative chunk",
 			cmd: &TestCmd{
@@ -126,3 +185,230 @@ func TestTestCmd_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestTestCmd_RerunFails(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		fail      bool
+		wantError bool
+		want      string
+	}{
+		{
+			name: "flaky test passes on rerun",
+			want: `{
+  "flaky": [
+    {
+      "package": "github.com/lox/gotestchunk/pkg/commands/testdata/flaky",
+      "test": "TestFlaky",
+      "attempts": 2
+    }
+  ],
+  "failed": []
+}`,
+		},
+		{
+			name:      "test that fails every attempt",
+			fail:      true,
+			wantError: true,
+			want: `{
+  "flaky": [
+    {
+      "package": "github.com/lox/gotestchunk/pkg/commands/testdata/flaky",
+      "test": "TestFlaky",
+      "attempts": 2
+    }
+  ],
+  "failed": [
+    {
+      "package": "github.com/lox/gotestchunk/pkg/commands/testdata/flaky",
+      "test": "TestFails",
+      "attempts": 3
+    }
+  ]
+}`,
+		},
+		{
+			name:      "flaky test reruns alongside a failed package",
+			args:      []string{"./pkg/commands/testdata/flaky", "./pkg/commands/testdata/setupfail"},
+			wantError: true,
+			want: `{
+  "flaky": [
+    {
+      "package": "github.com/lox/gotestchunk/pkg/commands/testdata/flaky",
+      "test": "TestFlaky",
+      "attempts": 2
+    }
+  ],
+  "failed": []
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		testlist.TestRunWithModuleRoot(t, tt.name, func(t *testing.T) {
+			testlist.SetFlakyEnv(t, tt.fail)
+
+			args := tt.args
+			if args == nil {
+				args = []string{"./pkg/commands/testdata/flaky"}
+			}
+			summary := filepath.Join(t.TempDir(), "summary.json")
+			cmd := &TestCmd{
+				Chunks:       1,
+				Chunk:        1,
+				RerunFails:   2,
+				RerunSummary: summary,
+				Args:         args,
+			}
+
+			logger := zerolog.New(zerolog.NewTestWriter(t))
+			err := cmd.Run(&logger)
+			if (err != nil) != tt.wantError {
+				t.Errorf("TestCmd.Run() error = %v, wantError %v", err, tt.wantError)
+			}
+			if tt.wantError && ExitCode(err) != ExitTestFailure {
+				t.Errorf("ExitCode() = %d, want %d for error %v", ExitCode(err), ExitTestFailure, err)
+			}
+
+			got, err := os.ReadFile(summary)
+			if err != nil {
+				t.Fatalf("os.ReadFile() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("summary = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestCmd_Interrupt(t *testing.T) {
+	testlist.TestRunWithModuleRoot(t, "interrupted run writes timings", func(t *testing.T) {
+		dir := t.TempDir()
+		started := filepath.Join(dir, "started")
+		t.Setenv("SLEEP_TEST", "1")
+		t.Setenv("SLEEP_STARTED", started)
+
+		// Interrupt once the sleeping test has started
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			for ctx.Err() == nil {
+				if _, err := os.Stat(started); err == nil {
+					cancel()
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+
+		timings := filepath.Join(dir, "timing.json")
+		cmd := &TestCmd{
+			Chunks:      1,
+			Chunk:       1,
+			WriteTiming: timings,
+			GracePeriod: time.Second,
+			Args:        []string{"./pkg/testrunner/testdata/sleep"},
+		}
+
+		logger := zerolog.New(zerolog.NewTestWriter(t))
+		err := cmd.run(ctx, &logger)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("TestCmd.run() error = %v, want context.Canceled", err)
+		}
+		if code := ExitCode(err); code != ExitInterrupted {
+			t.Errorf("ExitCode() = %d, want %d", code, ExitInterrupted)
+		}
+
+		got, err := os.ReadFile(timings)
+		if err != nil {
+			t.Fatalf("os.ReadFile() error = %v", err)
+		}
+		if !strings.Contains(string(got), "TestQuick") {
+			t.Errorf("timings = %s, want TestQuick, which passed before the interrupt", got)
+		}
+	})
+}
+
+func TestTestCmd_ChunkTimeout(t *testing.T) {
+	testlist.TestRunWithModuleRoot(t, "hung test fails", func(t *testing.T) {
+		t.Setenv("SLEEP_TEST", "1")
+
+		summary := filepath.Join(t.TempDir(), "summary.json")
+		cmd := &TestCmd{
+			Chunks:       1,
+			Chunk:        1,
+			RerunSummary: summary,
+			GracePeriod:  time.Second,
+			ChunkTimeout: 6 * time.Second,
+			Args:         []string{"./pkg/testrunner/testdata/sleep"},
+		}
+
+		logger := zerolog.New(zerolog.NewTestWriter(t))
+		err := cmd.run(context.Background(), &logger)
+		if code := ExitCode(err); code != ExitTimeout {
+			t.Errorf("ExitCode() = %d, want %d for error %v", code, ExitTimeout, err)
+		}
+
+		// The test that was running when the chunk timed out is named
+		got, err := os.ReadFile(summary)
+		if err != nil {
+			t.Fatalf("os.ReadFile() error = %v", err)
+		}
+		want := `{
+  "flaky": [],
+  "failed": [
+    {
+      "package": "github.com/lox/gotestchunk/pkg/testrunner/testdata/sleep",
+      "test": "TestSleep",
+      "attempts": 1
+    }
+  ]
+}`
+		if string(got) != want {
+			t.Errorf("summary = %s, want %s", got, want)
+		}
+	})
+}
+
+func TestTestCmd_GracePeriod(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *TestCmd
+		want time.Duration
+	}{
+		{
+			name: "no chunk timeout",
+			cmd:  &TestCmd{GracePeriod: time.Minute},
+			want: time.Minute,
+		},
+		{
+			name: "default",
+			cmd:  &TestCmd{},
+			want: testrunner.DefaultGracePeriod,
+		},
+		{
+			name: "within chunk timeout",
+			cmd:  &TestCmd{GracePeriod: 10 * time.Second, ChunkTimeout: time.Minute},
+			want: 10 * time.Second,
+		},
+		{
+			name: "as long as chunk timeout",
+			cmd:  &TestCmd{GracePeriod: time.Minute, ChunkTimeout: time.Minute},
+			want: 30 * time.Second,
+		},
+		{
+			name: "default longer than chunk timeout",
+			cmd:  &TestCmd{ChunkTimeout: 4 * time.Second},
+			want: 2 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cmd.gracePeriod(); got != tt.want {
+				t.Errorf("gracePeriod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
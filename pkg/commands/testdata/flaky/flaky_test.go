@@ -0,0 +1,33 @@
+package flaky
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFlaky fails the first time it runs with a counter directory, and
+// TestFails fails every time
+func TestFlaky(t *testing.T) {
+	dir := os.Getenv("FLAKY_DIR")
+	if dir == "" {
+		t.Skip("FLAKY_DIR not set")
+	}
+
+	counter := filepath.Join(dir, "count")
+	if _, err := os.Stat(counter); os.IsNotExist(err) {
+		if err := os.WriteFile(counter, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Fatal("failing the first attempt")
+	}
+}
+
+func TestFails(t *testing.T) {
+	if os.Getenv("FLAKY_DIR") == "" {
+		t.Skip("FLAKY_DIR not set")
+	}
+	if os.Getenv("FLAKY_FAIL") != "" {
+		t.Fatal("failing every attempt")
+	}
+}
@@ -0,0 +1,21 @@
+package setupfail
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestMain fails the package after its tests pass with a counter directory,
+// so the package fails without a failing test. Listing tests still works.
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if code == 0 && os.Getenv("FLAKY_DIR") != "" && flag.Lookup("test.list").Value.String() == "" {
+		fmt.Println("failing after the tests passed")
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func TestPasses(t *testing.T) {}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/lox/gotestchunk/pkg/timing"
+	"github.com/rs/zerolog"
+)
+
+// readTimings loads test timings and package overheads from files matching a
+// glob pattern. Both are empty if the pattern is empty or matches no files.
+func readTimings(logger *zerolog.Logger, pattern string) (*timing.Timings, error) {
+	if pattern == "" {
+		return &timing.Timings{}, nil
+	}
+
+	// Find all matching files
+	absPattern := pattern
+	if !filepath.IsAbs(absPattern) {
+		var err error
+		absPattern, err = filepath.Abs(absPattern)
+		if err != nil {
+			return nil, fmt.Errorf("error getting absolute path: %w", err)
+		}
+	}
+
+	// Use doublestar to find all matching files
+	fs := os.DirFS(filepath.Dir(absPattern))
+	matches, err := doublestar.Glob(fs, filepath.Base(absPattern))
+	if err != nil {
+		return nil, fmt.Errorf("error finding timing files: %w", err)
+	}
+
+	// Convert matches to full paths
+	files := make([]string, len(matches))
+	for i, match := range matches {
+		files[i] = filepath.Join(filepath.Dir(absPattern), match)
+	}
+
+	if len(files) == 0 {
+		logger.Warn().
+			Str("pattern", pattern).
+			Msg("No timing files found")
+		return &timing.Timings{}, nil
+	}
+
+	timings, err := timing.Load(files)
+	if err != nil {
+		return nil, fmt.Errorf("error loading timing data: %w", err)
+	}
+
+	logger.Debug().
+		Str("pattern", pattern).
+		Int("files", len(files)).
+		Int("timings", len(timings.Tests)).
+		Int("packages", len(timings.Packages)).
+		Msg("Loaded test timing information")
+
+	return timings, nil
+}
+
+// timingFile collects test timings and writes them to a file when flushed,
+// so timings of the tests that passed are kept even if the run fails or is
+// interrupted
+type timingFile struct {
+	timing.Collector
+	Filename string
+	Logger   *zerolog.Logger
+}
+
+// Flush writes the timings collected so far, if there are any
+func (f *timingFile) Flush() error {
+	if len(f.Tests) == 0 {
+		return nil
+	}
+	if err := timing.WriteToFile(f.Tests, f.Filename); err != nil {
+		return err
+	}
+
+	f.Logger.Info().
+		Str("file", f.Filename).
+		Int("tests", len(f.Tests)).
+		Msg("Wrote test timing information")
+	return nil
+}
+
+// logBalance reports how evenly estimated durations are spread across chunks
+func logBalance(logger *zerolog.Logger, balance testlist.Balance) {
+	durations := make([]string, len(balance.Durations))
+	for i, d := range balance.Durations {
+		durations[i] = d.Round(time.Millisecond).String()
+	}
+
+	logger.Info().
+		Strs("durations", durations).
+		Str("makespan", balance.Makespan.Round(time.Millisecond).String()).
+		Str("imbalance", fmt.Sprintf("%.1f%%", balance.Imbalance*100)).
+		Msg("Estimated chunk durations")
+}
@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/coordinator"
+	"github.com/lox/gotestchunk/pkg/testlist"
+	"github.com/lox/gotestchunk/pkg/testrunner"
+	"github.com/rs/zerolog"
+)
+
+// pollInterval is how long a worker waits before asking again when every
+// remaining batch is leased to other workers
+const pollInterval = time.Second
+
+// work runs batches of tests leased from a coordinator until every batch has
+// been completed by some worker, or ctx is done
+func (cmd *TestCmd) work(ctx context.Context, logger *zerolog.Logger, runner *testrunner.Runner, goTestArgs []string) error {
+	hostname, _ := os.Hostname()
+	client := &coordinator.Client{
+		URL:    cmd.Coordinator,
+		Worker: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+
+	// Exclusive tests still run on their own, although pins and groups
+	// don't apply to leased batches
+	rules, err := loadRules(cmd.Rules)
+	if err != nil {
+		return err
+	}
+
+	failures := &failureCollector{}
+	runner.AddHandler(failures)
+
+	var batches, failed int
+	for ctx.Err() == nil {
+		lease, done, err := client.Lease()
+		if err != nil {
+			return fmt.Errorf("error leasing tests: %w", err)
+		}
+		if done {
+			break
+		}
+		if lease == nil {
+			select {
+			case <-time.After(pollInterval):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		logger.Info().
+			Str("lease", lease.ID).
+			Strs("packages", testlist.Packages(lease.Batch.Tests)).
+			Int("tests", len(lease.Batch.Tests)).
+			Msg("Running leased tests")
+
+		failures.reset()
+		stop := keepLease(logger, client, lease)
+		runErr := cmd.runTests(ctx, logger, runner, goTestArgs, lease.Batch.Tests, nil, rules)
+		stop()
+
+		// The batch didn't finish, so its lease is left to expire and
+		// another worker runs it
+		if ctx.Err() != nil {
+			return fmt.Errorf("error running leased tests: %w", runErr)
+		}
+
+		result := coordinator.Result{Lease: lease.ID, Failed: failures.Failed}
+		if runErr != nil {
+			failed++
+			if len(result.Failed) == 0 {
+				result.Error = runErr.Error()
+			}
+		}
+
+		// Another worker may have completed the batch after our lease
+		// expired, in which case the result is simply dropped
+		if err := client.Complete(result); err != nil && !errors.Is(err, coordinator.ErrUnknownLease) {
+			return fmt.Errorf("error reporting results: %w", err)
+		}
+		batches++
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("stopped leasing tests: %w", context.Cause(ctx))
+	}
+
+	logger.Info().
+		Int("batches", batches).
+		Int("failed", failed).
+		Msg("Coordinator has no more tests")
+
+	if failed > 0 {
+		return fmt.Errorf("error running tests: %d of %d batches failed", failed, batches)
+	}
+	return nil
+}
+
+// keepLease renews a lease in the background until the returned function
+// is called
+func keepLease(logger *zerolog.Logger, client *coordinator.Client, lease *coordinator.Lease) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(lease.Timeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := client.Renew(lease.ID); err != nil {
+					logger.Warn().
+						Err(err).
+						Str("lease", lease.ID).
+						Msg("Failed to renew lease")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// failureCollector records the top-level tests and packages that fail,
+// forgetting tests that pass when rerun and packages that pass in a later
+// attempt than they failed
+type failureCollector struct {
+	Failed []string
+
+	attempts map[string]int // Attempt each failed package last failed in
+}
+
+// reset forgets every failure, ready for the next batch
+func (c *failureCollector) reset() {
+	c.Failed = nil
+	c.attempts = nil
+}
+
+// HandleEvent processes a test event
+func (c *failureCollector) HandleEvent(event testrunner.TestEvent) error {
+	if strings.Contains(event.Test, "/") {
+		return nil
+	}
+
+	name := event.Package
+	if event.Test != "" {
+		name += "." + event.Test
+	}
+	switch {
+	case event.Action == "fail":
+		if !slices.Contains(c.Failed, name) {
+			c.Failed = append(c.Failed, name)
+		}
+		if event.Test == "" {
+			if c.attempts == nil {
+				c.attempts = make(map[string]int)
+			}
+			c.attempts[name] = event.Attempt
+		}
+	case event.Flaky:
+		c.forget(name)
+	case event.Action == "pass" && event.Test == "":
+		// A package can run in several invocations of an attempt, so
+		// only a pass from a later attempt means its failures passed
+		if attempt, ok := c.attempts[name]; ok && event.Attempt > attempt {
+			c.forget(name)
+			delete(c.attempts, name)
+		}
+	}
+	return nil
+}
+
+// forget removes a test or package from the failures
+func (c *failureCollector) forget(name string) {
+	c.Failed = slices.DeleteFunc(c.Failed, func(failed string) bool { return failed == name })
+}
@@ -0,0 +1,96 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Client is used by workers to lease batches from a coordinator
+type Client struct {
+	URL    string // http://host:port, or unix:/path/to/socket
+	Worker string // Identifies this worker in the coordinator's logs
+
+	client  *http.Client
+	baseURL string
+}
+
+// Lease returns the next batch to run, see Queue.Lease
+func (c *Client) Lease() (*Lease, bool, error) {
+	var resp LeaseResponse
+	if err := c.post("/lease", LeaseRequest{Worker: c.Worker}, &resp); err != nil {
+		return nil, false, err
+	}
+	return resp.Lease, resp.Done, nil
+}
+
+// Renew extends a lease, see Queue.Renew
+func (c *Client) Renew(lease string) error {
+	return c.post("/renew", RenewRequest{Lease: lease}, nil)
+}
+
+// Complete reports the result of a batch, see Queue.Complete
+func (c *Client) Complete(result Result) error {
+	return c.post("/complete", result, nil)
+}
+
+// post sends a JSON request to the coordinator and decodes the response into out
+func (c *Client) post(path string, in, out any) error {
+	c.init()
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	resp, err := c.client.Post(c.baseURL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error contacting coordinator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return ErrUnknownLease
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("coordinator returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error parsing coordinator response: %w", err)
+	}
+	return nil
+}
+
+// init sets up the HTTP client, dialing a unix socket if the URL names one
+func (c *Client) init() {
+	if c.client != nil {
+		return
+	}
+
+	path, ok := socketPath(c.URL)
+	if !ok {
+		c.client = http.DefaultClient
+		c.baseURL = strings.TrimSuffix(c.URL, "/")
+		return
+	}
+
+	c.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	c.baseURL = "http://coordinator"
+}
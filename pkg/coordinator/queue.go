@@ -0,0 +1,262 @@
+package coordinator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+)
+
+// DefaultLeaseTimeout is how long a worker may go without renewing a lease
+// before its batch is given to another worker
+const DefaultLeaseTimeout = time.Minute
+
+// ErrUnknownLease is returned when renewing a lease that has expired or
+// whose batch has been completed
+var ErrUnknownLease = errors.New("unknown or expired lease")
+
+// Batch is a group of tests that is leased to a worker and run together
+type Batch struct {
+	ID       int             `json:"id"`
+	Tests    []testlist.Test `json:"tests"`
+	Duration time.Duration   `json:"duration"` // Estimated from timings
+}
+
+// Lease grants a worker a batch of tests to run. The worker must renew the
+// lease within Timeout or the batch is requeued.
+type Lease struct {
+	ID      string        `json:"id"`
+	Worker  string        `json:"worker"`
+	Batch   Batch         `json:"batch"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+// Result is reported by a worker once it has run a leased batch
+type Result struct {
+	Lease  string   `json:"lease"`
+	Failed []string `json:"failed,omitempty"` // Tests or packages that failed
+	Error  string   `json:"error,omitempty"`  // Set if the batch failed without a failing test, e.g. a build error
+}
+
+// Status summarises the progress of a queue
+type Status struct {
+	Queued    int      `json:"queued"`
+	Leased    int      `json:"leased"`
+	Completed int      `json:"completed"`
+	Requeued  int      `json:"requeued"`
+	Failed    []string `json:"failed,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// batchState tracks a batch through the queue
+type batchState struct {
+	batch    Batch
+	lease    string    // Current lease, empty when queued
+	worker   string    // Worker holding the current lease
+	deadline time.Time // When the current lease expires
+	attempts int
+	done     bool
+}
+
+// Queue hands out batches of tests to workers, longest first, and requeues
+// batches whose leases expire because a worker died or hung
+type Queue struct {
+	leaseTimeout time.Duration
+	now          func() time.Time
+
+	mu       sync.Mutex
+	batches  []*batchState // Longest first
+	leases   map[string]*batchState
+	status   Status
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewQueue returns a queue of the given batches. Batches are handed out in
+// order of estimated duration, longest first, so the slowest work starts
+// early and short batches fill in the gaps at the end.
+func NewQueue(batches []Batch, leaseTimeout time.Duration) *Queue {
+	if leaseTimeout <= 0 {
+		leaseTimeout = DefaultLeaseTimeout
+	}
+
+	q := &Queue{
+		leaseTimeout: leaseTimeout,
+		now:          time.Now,
+		leases:       make(map[string]*batchState),
+		done:         make(chan struct{}),
+	}
+	for _, batch := range batches {
+		q.batches = append(q.batches, &batchState{batch: batch})
+	}
+	sort.SliceStable(q.batches, func(i, j int) bool {
+		return q.batches[i].batch.Duration > q.batches[j].batch.Duration
+	})
+	q.status.Queued = len(q.batches)
+
+	if len(q.batches) == 0 {
+		q.finish()
+	}
+	return q
+}
+
+// NewBatches groups tests into batches of the given granularity, using the
+// chunker's timings to estimate the duration of each batch
+func NewBatches(tests []testlist.Test, granularity testlist.Granularity, chunker *testlist.Chunker) ([]Batch, error) {
+	units, err := testlist.Units(tests, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	batches := make([]Batch, len(units))
+	for i, unit := range units {
+		batches[i] = Batch{
+			ID:       i + 1,
+			Tests:    unit.Tests,
+			Duration: chunker.Estimate(unit.Tests),
+		}
+	}
+	return batches, nil
+}
+
+// Lease returns the next batch for a worker. It returns a nil lease and
+// false if every batch is leased but not yet complete, in which case the
+// worker should try again later, as a lease may yet expire. It returns true
+// once every batch is complete.
+func (q *Queue) Lease(worker string) (*Lease, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expire()
+
+	for _, b := range q.batches {
+		if b.done || b.lease != "" {
+			continue
+		}
+
+		b.attempts++
+		b.lease = fmt.Sprintf("%d-%d", b.batch.ID, b.attempts)
+		b.worker = worker
+		b.deadline = q.now().Add(q.leaseTimeout)
+		q.leases[b.lease] = b
+		q.status.Queued--
+		q.status.Leased++
+
+		return &Lease{
+			ID:      b.lease,
+			Worker:  worker,
+			Batch:   b.batch,
+			Timeout: q.leaseTimeout,
+		}, false
+	}
+
+	return nil, q.status.Completed == len(q.batches)
+}
+
+// Renew extends a lease, which workers do periodically while running a batch
+func (q *Queue) Renew(lease string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expire()
+
+	b, ok := q.leases[lease]
+	if !ok {
+		return ErrUnknownLease
+	}
+	b.deadline = q.now().Add(q.leaseTimeout)
+	return nil
+}
+
+// Complete records the result of a leased batch. A result for an expired
+// lease is still accepted if the batch hasn't been completed by another
+// worker since, as the tests did run.
+func (q *Queue) Complete(result Result) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	b, ok := q.leases[result.Lease]
+	if !ok {
+		b = q.batchForLease(result.Lease)
+		if b == nil {
+			return ErrUnknownLease
+		}
+	}
+	if b.done {
+		return nil
+	}
+
+	if b.lease != "" {
+		delete(q.leases, b.lease)
+		q.status.Leased--
+	} else {
+		q.status.Queued--
+	}
+	b.lease = ""
+	b.done = true
+	q.status.Completed++
+	q.status.Failed = append(q.status.Failed, result.Failed...)
+	if result.Error != "" {
+		q.status.Errors = append(q.status.Errors, result.Error)
+	}
+
+	if q.status.Completed == len(q.batches) {
+		q.finish()
+	}
+	return nil
+}
+
+// Status returns a summary of the queue's progress
+func (q *Queue) Status() Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expire()
+
+	status := q.status
+	status.Failed = append([]string(nil), q.status.Failed...)
+	status.Errors = append([]string(nil), q.status.Errors...)
+	return status
+}
+
+// Done returns a channel that is closed once every batch is complete
+func (q *Queue) Done() <-chan struct{} {
+	return q.done
+}
+
+// expire requeues batches whose leases have passed their deadline
+func (q *Queue) expire() {
+	now := q.now()
+	for id, b := range q.leases {
+		if now.Before(b.deadline) {
+			continue
+		}
+		delete(q.leases, id)
+		b.lease = ""
+		b.worker = ""
+		q.status.Leased--
+		q.status.Queued++
+		q.status.Requeued++
+	}
+}
+
+// batchForLease returns the batch that a lease, possibly expired, was for
+func (q *Queue) batchForLease(lease string) *batchState {
+	var id, attempt int
+	if _, err := fmt.Sscanf(lease, "%d-%d", &id, &attempt); err != nil {
+		return nil
+	}
+	for _, b := range q.batches {
+		if b.batch.ID == id && attempt <= b.attempts {
+			return b
+		}
+	}
+	return nil
+}
+
+func (q *Queue) finish() {
+	q.doneOnce.Do(func() { close(q.done) })
+}
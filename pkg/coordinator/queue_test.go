@@ -0,0 +1,146 @@
+package coordinator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lox/gotestchunk/pkg/testlist"
+)
+
+func testBatches() []Batch {
+	return []Batch{
+		{ID: 1, Tests: []testlist.Test{{Package: "pkg/a", Name: "TestA"}}, Duration: time.Second},
+		{ID: 2, Tests: []testlist.Test{{Package: "pkg/b", Name: "TestB"}}, Duration: 3 * time.Second},
+		{ID: 3, Tests: []testlist.Test{{Package: "pkg/c", Name: "TestC"}}, Duration: 2 * time.Second},
+	}
+}
+
+func TestQueueLongestFirst(t *testing.T) {
+	q := NewQueue(testBatches(), time.Minute)
+
+	var order []int
+	for {
+		lease, done := q.Lease("worker")
+		if done {
+			t.Fatal("Queue.Lease() reported done with batches outstanding")
+		}
+		if lease == nil {
+			break
+		}
+		order = append(order, lease.Batch.ID)
+	}
+
+	if want := []int{2, 3, 1}; !reflect.DeepEqual(order, want) {
+		t.Errorf("lease order = %v, want %v", order, want)
+	}
+}
+
+func TestQueueComplete(t *testing.T) {
+	q := NewQueue(testBatches(), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		lease, _ := q.Lease("worker")
+		result := Result{Lease: lease.ID}
+		if lease.Batch.ID == 3 {
+			result.Failed = []string{"pkg/c.TestC"}
+		}
+		if err := q.Complete(result); err != nil {
+			t.Fatalf("Queue.Complete() error = %v", err)
+		}
+	}
+
+	select {
+	case <-q.Done():
+	default:
+		t.Fatal("Queue.Done() not closed after every batch completed")
+	}
+
+	if _, done := q.Lease("worker"); !done {
+		t.Error("Queue.Lease() not done after every batch completed")
+	}
+
+	status := q.Status()
+	if status.Completed != 3 || status.Queued != 0 || status.Leased != 0 {
+		t.Errorf("Queue.Status() = %+v", status)
+	}
+	if !reflect.DeepEqual(status.Failed, []string{"pkg/c.TestC"}) {
+		t.Errorf("Queue.Status().Failed = %v", status.Failed)
+	}
+}
+
+func TestQueueRequeuesExpiredLeases(t *testing.T) {
+	now := time.Now()
+	q := NewQueue(testBatches()[:1], time.Minute)
+	q.now = func() time.Time { return now }
+
+	dead, _ := q.Lease("dead")
+	if lease, done := q.Lease("alive"); lease != nil || done {
+		t.Fatalf("Queue.Lease() = %v, %v, want nothing while leased", lease, done)
+	}
+
+	// Renewing keeps the lease alive past its original deadline
+	now = now.Add(50 * time.Second)
+	if err := q.Renew(dead.ID); err != nil {
+		t.Fatalf("Queue.Renew() error = %v", err)
+	}
+	now = now.Add(50 * time.Second)
+	if lease, _ := q.Lease("alive"); lease != nil {
+		t.Fatalf("Queue.Lease() = %v, want nothing while renewed", lease)
+	}
+
+	// Once the worker stops renewing, the batch goes to another worker
+	now = now.Add(time.Minute)
+	alive, _ := q.Lease("alive")
+	if alive == nil || alive.Batch.ID != dead.Batch.ID {
+		t.Fatalf("Queue.Lease() = %v, want requeued batch", alive)
+	}
+	if err := q.Renew(dead.ID); !errors.Is(err, ErrUnknownLease) {
+		t.Errorf("Queue.Renew() on expired lease error = %v, want ErrUnknownLease", err)
+	}
+	if got := q.Status().Requeued; got != 1 {
+		t.Errorf("Queue.Status().Requeued = %d, want 1", got)
+	}
+
+	// The first result wins, and a late result for the same batch is ignored
+	if err := q.Complete(Result{Lease: alive.ID}); err != nil {
+		t.Fatalf("Queue.Complete() error = %v", err)
+	}
+	if err := q.Complete(Result{Lease: dead.ID, Failed: []string{"pkg/a.TestA"}}); err != nil {
+		t.Fatalf("Queue.Complete() for late lease error = %v", err)
+	}
+	if status := q.Status(); status.Completed != 1 || len(status.Failed) != 0 {
+		t.Errorf("Queue.Status() = %+v", status)
+	}
+
+	if err := q.Complete(Result{Lease: "99-1"}); !errors.Is(err, ErrUnknownLease) {
+		t.Errorf("Queue.Complete() for unknown lease error = %v, want ErrUnknownLease", err)
+	}
+}
+
+func TestNewBatches(t *testing.T) {
+	tests := []testlist.Test{
+		{Package: "pkg/a", Name: "TestA"},
+		{Package: "pkg/a", Name: "TestB"},
+		{Package: "pkg/b", Name: "TestC"},
+	}
+	chunker := &testlist.Chunker{Timings: map[string]time.Duration{
+		"pkg/a.TestA": 2 * time.Second,
+		"pkg/a.TestB": 3 * time.Second,
+	}}
+
+	batches, err := NewBatches(tests, testlist.GranularityPackage, chunker)
+	if err != nil {
+		t.Fatalf("NewBatches() error = %v", err)
+	}
+	if len(batches) != 2 {
+		t.Fatalf("NewBatches() returned %d batches, want 2", len(batches))
+	}
+	if batches[0].Duration != 5*time.Second || len(batches[0].Tests) != 2 {
+		t.Errorf("batch 1 = %+v", batches[0])
+	}
+	if batches[1].Duration != time.Second {
+		t.Errorf("batch 2 duration = %v, want 1s default", batches[1].Duration)
+	}
+}
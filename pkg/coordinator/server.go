@@ -0,0 +1,128 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// LeaseRequest asks the coordinator for the next batch
+type LeaseRequest struct {
+	Worker string `json:"worker"`
+}
+
+// LeaseResponse holds the next batch for a worker. A nil Lease with Done
+// unset means every remaining batch is leased, and the worker should retry.
+type LeaseResponse struct {
+	Lease *Lease `json:"lease,omitempty"`
+	Done  bool   `json:"done"`
+}
+
+// RenewRequest extends a lease
+type RenewRequest struct {
+	Lease string `json:"lease"`
+}
+
+// Server exposes a queue to workers over HTTP
+type Server struct {
+	Queue  *Queue
+	Logger *zerolog.Logger
+}
+
+// ServeHTTP handles requests from workers
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == "/status" {
+		writeJSON(w, s.Queue.Status())
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/lease":
+		var req LeaseRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		lease, done := s.Queue.Lease(req.Worker)
+		if lease != nil {
+			s.Logger.Debug().
+				Str("worker", req.Worker).
+				Str("lease", lease.ID).
+				Int("tests", len(lease.Batch.Tests)).
+				Msg("Leased batch")
+		}
+		writeJSON(w, LeaseResponse{Lease: lease, Done: done})
+
+	case "/renew":
+		var req RenewRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if err := s.Queue.Renew(req.Lease); err != nil {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case "/complete":
+		var result Result
+		if !readJSON(w, r, &result) {
+			return
+		}
+		if err := s.Queue.Complete(result); err != nil {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		s.Logger.Debug().
+			Str("lease", result.Lease).
+			Strs("failed", result.Failed).
+			Msg("Completed batch")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Listen listens on a TCP address such as localhost:8080, or on a unix
+// socket given as unix:/path/to/socket
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := socketPath(addr); ok {
+		// Remove a socket left behind by a previous coordinator
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error removing stale socket: %w", err)
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// socketPath returns the path of a unix:path or unix://path address
+func socketPath(addr string) (string, bool) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		return path, true
+	}
+	return strings.CutPrefix(addr, "unix:")
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
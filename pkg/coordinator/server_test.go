@@ -0,0 +1,76 @@
+package coordinator
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestClientServer(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t))
+
+	for _, tt := range []struct {
+		name   string
+		listen func(t *testing.T, handler http.Handler) string
+	}{
+		{
+			name: "http",
+			listen: func(t *testing.T, handler http.Handler) string {
+				server := httptest.NewServer(handler)
+				t.Cleanup(server.Close)
+				return server.URL
+			},
+		},
+		{
+			name: "unix socket",
+			listen: func(t *testing.T, handler http.Handler) string {
+				addr := "unix:" + filepath.Join(t.TempDir(), "coordinator.sock")
+				listener, err := Listen(addr)
+				if err != nil {
+					t.Fatalf("Listen() error = %v", err)
+				}
+				server := &http.Server{Handler: handler}
+				go func() { _ = server.Serve(listener) }()
+				t.Cleanup(func() { server.Close() })
+				return addr
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue(testBatches(), time.Minute)
+			url := tt.listen(t, &Server{Queue: q, Logger: &logger})
+			client := &Client{URL: url, Worker: "worker"}
+
+			for {
+				lease, done, err := client.Lease()
+				if err != nil {
+					t.Fatalf("Client.Lease() error = %v", err)
+				}
+				if done {
+					break
+				}
+				if lease == nil {
+					t.Fatal("Client.Lease() returned no lease with nothing leased")
+				}
+				if err := client.Renew(lease.ID); err != nil {
+					t.Fatalf("Client.Renew() error = %v", err)
+				}
+				if err := client.Complete(Result{Lease: lease.ID}); err != nil {
+					t.Fatalf("Client.Complete() error = %v", err)
+				}
+			}
+
+			if got := q.Status().Completed; got != 3 {
+				t.Errorf("completed %d batches, want 3", got)
+			}
+			if err := client.Renew("1-1"); !errors.Is(err, ErrUnknownLease) {
+				t.Errorf("Client.Renew() of completed lease error = %v, want ErrUnknownLease", err)
+			}
+		})
+	}
+}
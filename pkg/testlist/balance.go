@@ -0,0 +1,250 @@
+package testlist
+
+import (
+	"sort"
+	"time"
+)
+
+// maxSearchIterations bounds the local search in chunkBalanced. A fixed
+// number of iterations rather than a time limit keeps the result identical
+// on every shard, however fast or busy the machine is.
+const maxSearchIterations = 10000
+
+// Balance describes how evenly estimated work is spread across chunks
+type Balance struct {
+	Durations []time.Duration // Estimated duration of each chunk
+	Makespan  time.Duration   // Estimated duration of the longest chunk
+	Imbalance float64         // How far the longest chunk is above its share of the mean, e.g. 0.2 for 20%
+}
+
+// Balance returns the estimated balance of chunks, using the chunker's
+// timings to estimate the duration of each test. With capacities, imbalance
+// is measured against each chunk's share of the work.
+func (c *Chunker) Balance(chunks [][]Test) Balance {
+	durations := make([]time.Duration, len(chunks))
+	for i, chunk := range chunks {
+		durations[i] = c.Estimate(chunk)
+	}
+
+	in := Input{Total: len(chunks)}
+	if len(c.Capacities) == len(chunks) {
+		in.Capacities = c.Capacities
+	}
+	return newBalance(in, durations)
+}
+
+// newBalance summarises the given chunk durations, scaling each by its
+// chunk's share of the work to find the imbalance
+func newBalance(in Input, durations []time.Duration) Balance {
+	b := Balance{Durations: durations}
+	if len(durations) == 0 {
+		return b
+	}
+
+	var sum, longest time.Duration
+	for i, d := range durations {
+		sum += d
+		b.Makespan = max(b.Makespan, d)
+		longest = max(longest, in.scaled(i, d))
+	}
+
+	mean := float64(sum) / float64(len(durations))
+	if mean > 0 {
+		b.Imbalance = (float64(longest) - mean) / mean
+	}
+	return b
+}
+
+// chunkBalanced assigns units with the longest processing time algorithm and
+// then improves the result with a local search. The search repeatedly takes
+// the longest chunk and either moves one of its units to another chunk or
+// swaps it for a shorter unit, whichever best reduces the longer of the two
+// chunks. It stops when no move or swap helps, or after maxSearchIterations.
+func chunkBalanced(in Input) [][]Test {
+	_, assigned := assignGreedy(in)
+	if in.Total > 1 {
+		improveAssignment(in, assigned)
+	}
+
+	// Units are kept in their original order within each chunk
+	chunks := make([][]Test, in.Total)
+	for u, chunk := range assigned {
+		chunks[chunk] = append(chunks[chunk], in.Units[u].Tests...)
+	}
+	return chunks
+}
+
+// improveAssignment runs the local search for chunkBalanced, updating assigned
+func improveAssignment(in Input, assigned []int) {
+	loads := make([]time.Duration, in.Total)
+	members := make([][]int, in.Total)
+	counts := make([]map[string]int, in.Total) // Units of each package in each chunk
+	for i := range counts {
+		counts[i] = make(map[string]int)
+	}
+	for u, chunk := range assigned {
+		loads[chunk] += in.Weights[u]
+		if counts[chunk][in.pkg(u)] == 0 {
+			loads[chunk] += in.overhead(u)
+		}
+		counts[chunk][in.pkg(u)]++
+		members[chunk] = append(members[chunk], u)
+	}
+
+	// loadAfter returns the load of a chunk after taking out one unit and
+	// putting in another, either of which may be -1 for none. A package's
+	// overhead is paid while the chunk has any of its units.
+	loadAfter := func(chunk, remove, add int) time.Duration {
+		load := loads[chunk]
+		if remove >= 0 {
+			load -= in.Weights[remove]
+			if counts[chunk][in.pkg(remove)] == 1 {
+				load -= in.overhead(remove)
+			}
+		}
+		if add >= 0 {
+			load += in.Weights[add]
+			n := counts[chunk][in.pkg(add)]
+			if remove >= 0 && in.pkg(remove) == in.pkg(add) {
+				n--
+			}
+			if n == 0 {
+				load += in.overhead(add)
+			}
+		}
+		return load
+	}
+
+	// Keep each chunk's units sorted by weight, so swaps can be found with a
+	// binary search rather than by comparing every pair of units. With
+	// overheads, units are also kept sorted by weight within each package.
+	byPackage := make([]map[string][]int, in.Total)
+	byWeight := func(chunk int) {
+		sort.SliceStable(members[chunk], func(a, b int) bool {
+			ua, ub := members[chunk][a], members[chunk][b]
+			if in.Weights[ua] != in.Weights[ub] {
+				return in.Weights[ua] < in.Weights[ub]
+			}
+			return ua < ub
+		})
+		if in.Overheads != nil {
+			byPackage[chunk] = make(map[string][]int)
+			for _, u := range members[chunk] {
+				byPackage[chunk][in.pkg(u)] = append(byPackage[chunk][in.pkg(u)], u)
+			}
+		}
+	}
+
+	// nearest returns the units either side of a target weight in a sorted list
+	nearest := func(units []int, target time.Duration) []int {
+		i := sort.Search(len(units), func(i int) bool {
+			return in.Weights[units[i]] >= target
+		})
+		var near []int
+		for _, j := range []int{i - 1, i} {
+			if j >= 0 && j < len(units) {
+				near = append(near, units[j])
+			}
+		}
+		return near
+	}
+	for chunk := range members {
+		byWeight(chunk)
+	}
+
+	for iteration := 0; iteration < maxSearchIterations; iteration++ {
+		// Find the longest chunk, preferring the lowest index
+		longest := 0
+		for i := 1; i < in.Total; i++ {
+			if in.scaled(i, loads[i]) > in.scaled(longest, loads[longest]) {
+				longest = i
+			}
+		}
+
+		// Find the move or swap that leaves the pair of chunks with the
+		// shortest maximum, which must be shorter than the longest chunk
+		best := in.scaled(longest, loads[longest])
+		bestUnit, bestOther, bestDest := -1, -1, -1
+
+		// Packages in the longest chunk, in a consistent order
+		var packages []string
+		if in.Overheads != nil {
+			seen := make(map[string]bool)
+			for _, u := range members[longest] {
+				if !seen[in.pkg(u)] {
+					seen[in.pkg(u)] = true
+					packages = append(packages, in.pkg(u))
+				}
+			}
+		}
+
+		for _, u := range members[longest] {
+			if in.pinned(u) >= 0 {
+				continue
+			}
+			w := in.Weights[u]
+			for dest := 0; dest < in.Total; dest++ {
+				if dest == longest {
+					continue
+				}
+				if pair := max(in.scaled(longest, loadAfter(longest, u, -1)), in.scaled(dest, loadAfter(dest, -1, u))); pair < best {
+					best, bestUnit, bestOther, bestDest = pair, u, -1, dest
+				}
+
+				// Swapping for a unit of weight w-gap/2 would even out the
+				// pair, so check the units either side of that weight
+				target := w - (loads[longest]-loads[dest])/2
+				if in.Capacities != nil {
+					// Even out the pair relative to each chunk's share
+					sl, sd := in.share(longest), in.share(dest)
+					target = time.Duration((float64(loads[dest]+w)/sd - float64(loads[longest]-w)/sl) / (1/sl + 1/sd))
+				}
+				candidates := nearest(members[dest], target)
+
+				// Taking the last unit of a package out of the longest chunk
+				// saves its overhead, if the unit swapped back is from a
+				// package that the longest chunk already pays for
+				if in.Overheads != nil && counts[longest][in.pkg(u)] == 1 {
+					for _, pkg := range packages {
+						candidates = append(candidates, nearest(byPackage[dest][pkg], target)...)
+					}
+				}
+
+				for _, v := range candidates {
+					if in.pinned(v) >= 0 {
+						continue
+					}
+					if pair := max(in.scaled(longest, loadAfter(longest, u, v)), in.scaled(dest, loadAfter(dest, v, u))); pair < best {
+						best, bestUnit, bestOther, bestDest = pair, u, v, dest
+					}
+				}
+			}
+		}
+
+		if bestUnit == -1 {
+			return
+		}
+
+		move := func(u, from, to int) {
+			assigned[u] = to
+			loads[from] = loadAfter(from, u, -1)
+			counts[from][in.pkg(u)]--
+			loads[to] = loadAfter(to, -1, u)
+			counts[to][in.pkg(u)]++
+			for i, m := range members[from] {
+				if m == u {
+					members[from] = append(members[from][:i], members[from][i+1:]...)
+					break
+				}
+			}
+			members[to] = append(members[to], u)
+		}
+
+		move(bestUnit, longest, bestDest)
+		if bestOther != -1 {
+			move(bestOther, bestDest, longest)
+		}
+		byWeight(longest)
+		byWeight(bestDest)
+	}
+}
@@ -0,0 +1,145 @@
+package testlist
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChunkBalanced(t *testing.T) {
+	// Greedy assignment gives chunks of 7s and 5s, where 6s and 6s is possible
+	var tests []Test
+	timings := make(map[string]time.Duration)
+	for i, seconds := range []int{3, 3, 2, 2, 2} {
+		test := Test{Package: "pkg/a", Name: fmt.Sprintf("Test%d", i)}
+		tests = append(tests, test)
+		timings[test.String()] = time.Duration(seconds) * time.Second
+	}
+
+	greedy := &Chunker{Strategy: StrategyFunc(chunkGreedy), Timings: timings}
+	greedyChunks, err := greedy.Chunks(tests, 2)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+	if got := greedy.Balance(greedyChunks).Makespan; got != 7*time.Second {
+		t.Fatalf("greedy makespan = %v, want 7s", got)
+	}
+
+	balanced := &Chunker{Strategy: StrategyFunc(chunkBalanced), Timings: timings}
+	chunks, err := balanced.Chunks(tests, 2)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+
+	balance := balanced.Balance(chunks)
+	if balance.Makespan != 6*time.Second {
+		t.Errorf("balanced makespan = %v, want 6s", balance.Makespan)
+	}
+	if balance.Imbalance != 0 {
+		t.Errorf("balanced imbalance = %v, want 0", balance.Imbalance)
+	}
+
+	// Every shard must compute the same plan
+	again, err := balanced.Chunks(tests, 2)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+	if !reflect.DeepEqual(chunks, again) {
+		t.Errorf("Chunker.Chunks() = %v, then %v", chunks, again)
+	}
+}
+
+func TestChunkBalancedNeverWorseThanGreedy(t *testing.T) {
+	tests := generateTests(97)
+	timings := make(map[string]time.Duration)
+	for i, test := range tests {
+		// A few long tests among many short ones
+		d := time.Duration(i*37%11+1) * 50 * time.Millisecond
+		if i%23 == 0 {
+			d = time.Duration(i%5+3) * time.Second
+		}
+		timings[test.String()] = d
+	}
+
+	for _, total := range []int{2, 3, 5, 8} {
+		greedy := &Chunker{Strategy: StrategyFunc(chunkGreedy), Timings: timings}
+		balanced := &Chunker{Strategy: StrategyFunc(chunkBalanced), Timings: timings}
+
+		greedyChunks, _ := greedy.Chunks(tests, total)
+		balancedChunks, _ := balanced.Chunks(tests, total)
+
+		greedyMakespan := greedy.Balance(greedyChunks).Makespan
+		balancedMakespan := balanced.Balance(balancedChunks).Makespan
+		if balancedMakespan > greedyMakespan {
+			t.Errorf("%d chunks: balanced makespan %v worse than greedy %v", total, balancedMakespan, greedyMakespan)
+		}
+
+		count := 0
+		for _, chunk := range balancedChunks {
+			count += len(chunk)
+		}
+		if count != len(tests) {
+			t.Errorf("%d chunks: assigned %d tests, want %d", total, count, len(tests))
+		}
+	}
+}
+
+func TestChunkerBalance(t *testing.T) {
+	chunker := &Chunker{Timings: map[string]time.Duration{
+		"pkg/a.Test1": 3 * time.Second,
+		"pkg/a.Test2": 1 * time.Second,
+	}}
+
+	got := chunker.Balance([][]Test{
+		{{Package: "pkg/a", Name: "Test1"}},
+		{{Package: "pkg/a", Name: "Test2"}},
+	})
+
+	want := Balance{
+		Durations: []time.Duration{3 * time.Second, 1 * time.Second},
+		Makespan:  3 * time.Second,
+		Imbalance: 0.5,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Chunker.Balance() = %+v, want %+v", got, want)
+	}
+}
+
+func TestChunkerOverheads(t *testing.T) {
+	var tests []Test
+	timings := make(map[string]time.Duration)
+	for _, pkg := range []string{"pkg/a", "pkg/b"} {
+		for _, name := range []string{"Test1", "Test2"} {
+			test := Test{Package: pkg, Name: name}
+			tests = append(tests, test)
+			timings[test.String()] = time.Second
+		}
+	}
+	overheads := map[string]time.Duration{
+		"pkg/a": 10 * time.Second,
+		"pkg/b": 10 * time.Second,
+	}
+
+	// Overhead is paid once for each package a chunk runs
+	chunker := &Chunker{Timings: timings, Overheads: overheads}
+	if got, want := chunker.Estimate(tests), 24*time.Second; got != want {
+		t.Errorf("Chunker.Estimate() = %v, want %v", got, want)
+	}
+
+	// Splitting both packages across chunks would pay both overheads twice,
+	// so the balanced strategy keeps each package's tests together
+	balanced := &Chunker{Strategy: StrategyFunc(chunkBalanced), Timings: timings, Overheads: overheads}
+	chunks, err := balanced.Chunks(tests, 2)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+	if got, want := balanced.Balance(chunks).Makespan, 12*time.Second; got != want {
+		t.Errorf("balanced makespan = %v, want %v (chunks %v)", got, want, chunks)
+	}
+	for i, chunk := range chunks {
+		if got := Packages(chunk); len(got) != 1 {
+			t.Errorf("chunk %d has packages %v, want one", i+1, got)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"math"
 	"os"
 	"path/filepath"
-	"sort"
 	"time"
 )
 
@@ -61,99 +60,289 @@ func LoadTimings(pattern string) (map[string]time.Duration, error) {
 	return result, nil
 }
 
-// ChunkByTiming splits tests into chunks trying to balance total execution time
-func ChunkByTiming(tests []Test, index, total int, timings map[string]time.Duration) ([]Test, error) {
-	if index < 0 || index >= total {
-		return nil, fmt.Errorf("chunk index %d out of bounds (total chunks: %d)", index, total)
+// Granularity is the smallest group of tests that is assigned to a chunk
+type Granularity string
+
+const (
+	// GranularityTest assigns each test to a chunk independently
+	GranularityTest Granularity = "test"
+	// GranularityPackage assigns all of a package's tests to the same chunk,
+	// so expensive package setup such as TestMain only runs once
+	GranularityPackage Granularity = "package"
+	// GranularityFile assigns all of the tests defined in a _test.go file to
+	// the same chunk. Tests without a known file are grouped by package.
+	GranularityFile Granularity = "file"
+)
+
+// Unit is a group of tests that are always assigned to the same chunk
+type Unit struct {
+	Key   string // Identifies the unit, e.g. a test, file or package name
+	Tests []Test
+}
+
+// Units groups tests into units of the given granularity. Tests are sorted
+// first, so units are returned in a consistent order.
+func Units(tests []Test, granularity Granularity) ([]Unit, error) {
+	var keyOf func(Test) string
+	switch granularity {
+	case "", GranularityTest:
+		keyOf = Test.String
+	case GranularityPackage:
+		keyOf = func(t Test) string { return t.Package }
+	case GranularityFile:
+		keyOf = func(t Test) string {
+			if t.File == "" {
+				return t.Package
+			}
+			return t.File
+		}
+	default:
+		return nil, fmt.Errorf("unknown granularity: %s", granularity)
 	}
 
 	// First sort tests to ensure consistent chunking
 	Sort(tests)
 
-	// Create chunks with timing information
-	type testWithTiming struct {
-		test Test
-		time time.Duration
-	}
-
-	// Get timing for each test, using default if not found
-	defaultTime := time.Second // default duration for tests without timing data
-	testsWithTiming := make([]testWithTiming, len(tests))
-	for i, test := range tests {
-		key := test.Package + "." + test.Name
-		time, ok := timings[key]
+	var units []Unit
+	index := make(map[string]int)
+	for _, test := range tests {
+		key := keyOf(test)
+		i, ok := index[key]
 		if !ok {
-			time = defaultTime
+			i = len(units)
+			index[key] = i
+			units = append(units, Unit{Key: key})
 		}
-		testsWithTiming[i] = testWithTiming{test, time}
+		units[i].Tests = append(units[i].Tests, test)
 	}
 
-	// Sort by duration (longest first)
-	sort.Slice(testsWithTiming, func(i, j int) bool {
-		return testsWithTiming[i].time > testsWithTiming[j].time
-	})
+	return units, nil
+}
 
-	// Create chunks and track their total times
-	chunks := make([][]Test, total)
-	chunkTimes := make([]time.Duration, total)
-
-	// Distribute tests using a greedy algorithm
-	for _, t := range testsWithTiming {
-		// Find chunk with smallest total time
-		minIndex := 0
-		minTime := chunkTimes[0]
-		for i := 1; i < total; i++ {
-			if chunkTimes[i] < minTime {
-				minIndex = i
-				minTime = chunkTimes[i]
-			}
-		}
+// Chunker splits tests into chunks
+type Chunker struct {
+	// Granularity is the smallest group of tests assigned to a chunk,
+	// defaults to GranularityTest
+	Granularity Granularity
 
-		chunks[minIndex] = append(chunks[minIndex], t.test)
-		chunkTimes[minIndex] += t.time
-	}
+	// Timings are test durations keyed by Test.String(). When set, units
+	// are weighted by duration rather than by number of tests.
+	Timings map[string]time.Duration
 
-	return chunks[index], nil
+	// Strategy assigns units to chunks, defaults to the auto strategy which
+	// splits units contiguously, or by duration when Timings are set
+	Strategy Strategy
+
+	// Estimator estimates the duration of tests missing from Timings,
+	// defaults to DefaultTestTime for every test
+	Estimator Estimator
+
+	// Overheads are the fixed cost of running each package, such as
+	// building its test binary, keyed by package. It is added once for
+	// every package that a chunk runs tests from.
+	Overheads map[string]time.Duration
+
+	// Capacities are the relative capacity of each chunk, e.g. 2, 2, 1, 1
+	// to give the first two chunks twice the work of the others. When set
+	// there must be one for every chunk; nil gives every chunk an equal share.
+	Capacities []float64
+
+	// SplitSubtests splits top-level tests estimated to take longer than
+	// this into their subtests, using subtest names and durations from
+	// Timings. Zero disables splitting, which only applies at test
+	// granularity.
+	SplitSubtests time.Duration
+
+	// Rules pin tests to chunks, give exclusive tests chunks of their own,
+	// and keep groups of tests together. Rules built in code rather than
+	// read from a file must be checked with Rules.Validate first.
+	Rules *Rules
 }
 
-// Chunks splits a slice of tests into n roughly equal chunks
-func Chunks(tests []Test, total int) [][]Test {
+// Chunks splits tests into the given number of chunks
+func (c *Chunker) Chunks(tests []Test, total int) ([][]Test, error) {
 	if total <= 0 {
 		total = 1
 	}
-	if len(tests) == 0 {
-		return make([][]Test, total)
+
+	if err := ValidateCapacities(c.Capacities, total); err != nil {
+		return nil, err
 	}
 
-	// First sort tests to ensure consistent chunking
-	Sort(tests)
+	units, err := c.units(tests)
+	if err != nil {
+		return nil, err
+	}
+
+	in := Input{
+		Units:      units,
+		Weights:    make([]time.Duration, len(units)),
+		Timed:      c.Timings != nil,
+		Total:      total,
+		Overheads:  c.Overheads,
+		Capacities: c.Capacities,
+	}
+	for i, unit := range units {
+		in.Weights[i] = c.unitTime(unit)
+	}
 
-	// Calculate chunk sizes
-	chunkSize := int(math.Ceil(float64(len(tests)) / float64(total)))
-	result := make([][]Test, 0, total)
+	strategy := c.Strategy
+	if strategy == nil {
+		strategy = autoStrategy{}
+	}
 
-	for i := 0; i < len(tests); i += chunkSize {
-		end := i + chunkSize
-		if end > len(tests) {
-			end = len(tests)
-		}
-		result = append(result, tests[i:end])
+	if c.Rules != nil {
+		return c.Rules.chunks(in, strategy)
+	}
+	return runStrategy(strategy, in)
+}
+
+// units splits tests into units of the chunker's granularity, splitting
+// long tests into their subtests and merging units grouped by rules
+func (c *Chunker) units(tests []Test) ([]Unit, error) {
+	units, err := Units(c.splitSubtests(tests), c.Granularity)
+	if err != nil {
+		return nil, err
+	}
+	if c.Rules != nil {
+		units = c.Rules.group(units)
+	}
+	return units, nil
+}
+
+// runStrategy returns the chunks from a strategy, checking that it returned
+// one for each chunk
+func runStrategy(strategy Strategy, in Input) ([][]Test, error) {
+	chunks := strategy.Chunks(in)
+	if len(chunks) != in.Total {
+		return nil, fmt.Errorf("strategy returned %d chunks, want %d", len(chunks), in.Total)
+	}
+	return chunks, nil
+}
+
+// ChunksWithin splits tests into as few chunks as possible while keeping the
+// estimated duration of every chunk within target. No number of chunks can
+// beat the longest unit, so a unit estimated to take longer than target
+// becomes the target instead. The search also stops once another chunk no
+// longer shortens the longest chunk.
+func (c *Chunker) ChunksWithin(tests []Test, target time.Duration) ([][]Test, error) {
+	units, err := c.units(tests)
+	if err != nil {
+		return nil, err
+	}
+	if target <= 0 {
+		return nil, fmt.Errorf("target duration must be positive")
+	}
+	if c.Capacities != nil {
+		return nil, fmt.Errorf("capacities fix the number of chunks, so can't be used with a target duration")
 	}
 
-	// If we have fewer chunks than requested, pad with empty slices
-	for len(result) < total {
-		result = append(result, []Test{})
+	for _, unit := range units {
+		target = max(target, c.Estimate(unit.Tests))
+	}
+	total := c.Estimate(tests)
+
+	// No fewer chunks than it takes to fit the total, and no more than one
+	// per unit, beyond which extra chunks would be empty. Tests pinned to a
+	// chunk need at least that many chunks.
+	n := max(min(int((total+target-1)/target), len(units)), c.Rules.maxChunk(), 1)
+	var best [][]Test
+	var bestMakespan time.Duration
+	for ; ; n++ {
+		chunks, err := c.Chunks(tests, n)
+		if err != nil {
+			return nil, err
+		}
+		makespan := c.Balance(chunks).Makespan
+		if best != nil && makespan >= bestMakespan {
+			return best, nil
+		}
+		if n >= len(units) || makespan <= target {
+			return chunks, nil
+		}
+		best, bestMakespan = chunks, makespan
 	}
+}
 
-	return result
+// ValidateCapacities checks that there is a positive capacity for each of
+// total chunks, or no capacities at all
+func ValidateCapacities(capacities []float64, total int) error {
+	if capacities == nil {
+		return nil
+	}
+	if len(capacities) != total {
+		return fmt.Errorf("got %d capacities for %d chunks", len(capacities), total)
+	}
+	for i, capacity := range capacities {
+		if capacity <= 0 || math.IsInf(capacity, 0) || math.IsNaN(capacity) {
+			return fmt.Errorf("capacity of chunk %d must be positive, got %v", i+1, capacity)
+		}
+	}
+	return nil
 }
 
 // Chunk returns a specific chunk of tests given an index and total number of chunks
-func Chunk(tests []Test, index, total int) ([]Test, error) {
+func (c *Chunker) Chunk(tests []Test, index, total int) ([]Test, error) {
 	if index < 0 || index >= total {
 		return nil, fmt.Errorf("chunk index %d out of bounds (total chunks: %d)", index, total)
 	}
 
-	chunks := Chunks(tests, total)
+	chunks, err := c.Chunks(tests, total)
+	if err != nil {
+		return nil, err
+	}
 	return chunks[index], nil
 }
+
+// Estimate returns the estimated duration of running the given tests, using
+// the chunker's timings, including the overhead of each of their packages
+func (c *Chunker) Estimate(tests []Test) time.Duration {
+	total := c.unitTime(Unit{Tests: tests})
+	if c.Overheads != nil {
+		for _, pkg := range Packages(tests) {
+			total += c.Overheads[pkg]
+		}
+	}
+	return total
+}
+
+// ChunkByTiming splits tests into chunks trying to balance total execution time
+func ChunkByTiming(tests []Test, index, total int, timings map[string]time.Duration) ([]Test, error) {
+	chunker := &Chunker{Timings: timings}
+	return chunker.Chunk(tests, index, total)
+}
+
+// Chunks splits a slice of tests into n roughly equal chunks
+func Chunks(tests []Test, total int) [][]Test {
+	chunks, _ := (&Chunker{}).Chunks(tests, total)
+	return chunks
+}
+
+// Chunk returns a specific chunk of tests given an index and total number of chunks
+func Chunk(tests []Test, index, total int) ([]Test, error) {
+	return (&Chunker{}).Chunk(tests, index, total)
+}
+
+// unitTime returns the total duration of a unit's tests
+func (c *Chunker) unitTime(unit Unit) time.Duration {
+	var total time.Duration
+	for _, test := range unit.Tests {
+		total += c.testTime(test)
+	}
+	return total
+}
+
+// testTime returns the duration of a test, estimating it if there is no
+// timing data for the test
+func (c *Chunker) testTime(test Test) time.Duration {
+	if test.Skip != "" {
+		return c.restTime(test)
+	}
+	if d, ok := c.Timings[test.String()]; ok {
+		return d
+	}
+	if c.Estimator != nil {
+		return c.Estimator.Estimate(test)
+	}
+	return DefaultTestTime
+}
@@ -1,6 +1,7 @@
 package testlist
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
@@ -314,3 +315,217 @@ func sortTests(tests []Test) {
 		return tests[i].Name < tests[j].Name
 	})
 }
+
+func TestChunkerGranularity(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "Test1"},
+		{Package: "pkg/a", Name: "Test2"},
+		{Package: "pkg/a", Name: "Test3"},
+		{Package: "pkg/b", Name: "Test4"},
+		{Package: "pkg/c", Name: "Test5"},
+		{Package: "pkg/c", Name: "Test6"},
+	}
+
+	testCases := []struct {
+		name    string
+		chunker Chunker
+		want    [][]Test
+	}{
+		{
+			name:    "contiguous packages",
+			chunker: Chunker{Granularity: GranularityPackage},
+			want: [][]Test{
+				{tests[0], tests[1], tests[2], tests[3]},
+				{tests[4], tests[5]},
+			},
+		},
+		{
+			name: "packages balanced by summed timing",
+			chunker: Chunker{
+				Granularity: GranularityPackage,
+				Timings: map[string]time.Duration{
+					"pkg/a.Test1": 100 * time.Millisecond,
+					"pkg/a.Test2": 100 * time.Millisecond,
+					"pkg/a.Test3": 100 * time.Millisecond,
+					"pkg/b.Test4": 250 * time.Millisecond,
+					"pkg/c.Test5": 25 * time.Millisecond,
+					"pkg/c.Test6": 25 * time.Millisecond,
+				},
+			},
+			want: [][]Test{
+				{tests[0], tests[1], tests[2]},
+				{tests[3], tests[4], tests[5]},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.chunker.Chunks(tests, 2)
+			if err != nil {
+				t.Fatalf("Chunker.Chunks() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Chunker.Chunks() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChunksWithin(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "Test1"},
+		{Package: "pkg/a", Name: "Test2"},
+		{Package: "pkg/b", Name: "Test3"},
+		{Package: "pkg/b", Name: "Test4"},
+		{Package: "pkg/c", Name: "Test5"},
+	}
+	timings := map[string]time.Duration{
+		"pkg/a.Test1": 4 * time.Minute,
+		"pkg/a.Test2": 3 * time.Minute,
+		"pkg/b.Test3": 2 * time.Minute,
+		"pkg/b.Test4": 2 * time.Minute,
+		"pkg/c.Test5": time.Minute,
+	}
+
+	// One test takes twice the target, alongside ten short ones
+	longTests := []Test{{Package: "pkg/a", Name: "TestLong"}}
+	longTimings := map[string]time.Duration{"pkg/a.TestLong": 10 * time.Minute}
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("Test%d", i)
+		longTests = append(longTests, Test{Package: "pkg/b", Name: name})
+		longTimings["pkg/b."+name] = time.Minute
+	}
+
+	testCases := []struct {
+		name       string
+		chunker    Chunker
+		tests      []Test
+		target     time.Duration
+		wantChunks int
+	}{
+		{
+			name:       "fits in total divided by target",
+			chunker:    Chunker{Timings: timings},
+			target:     6 * time.Minute,
+			wantChunks: 2,
+		},
+		{
+			name:       "needs more chunks than the lower bound",
+			chunker:    Chunker{Timings: timings},
+			target:     4 * time.Minute,
+			wantChunks: 3,
+		},
+		{
+			name:       "everything in one chunk",
+			chunker:    Chunker{Timings: timings},
+			target:     time.Hour,
+			wantChunks: 1,
+		},
+		{
+			name:       "single test longer than target",
+			chunker:    Chunker{Timings: timings},
+			target:     time.Minute,
+			wantChunks: 3,
+		},
+		{
+			name:       "longest test sets the target",
+			chunker:    Chunker{Timings: longTimings},
+			tests:      longTests,
+			target:     5 * time.Minute,
+			wantChunks: 2,
+		},
+		{
+			name:       "whole packages",
+			chunker:    Chunker{Granularity: GranularityPackage, Timings: timings},
+			target:     4 * time.Minute,
+			wantChunks: 2,
+		},
+		{
+			name:       "grouped tests count as one unit",
+			chunker:    Chunker{Timings: timings, Rules: mustRules(t, `{"rules": [{"match": "pkg/a.*", "group": "a"}]}`)},
+			target:     time.Minute,
+			wantChunks: 2,
+		},
+		{
+			name: "split subtests count as units",
+			chunker: Chunker{
+				Timings: map[string]time.Duration{
+					"pkg/a.Test1":   4 * time.Minute,
+					"pkg/a.Test1/x": 2 * time.Minute,
+					"pkg/a.Test1/y": 2 * time.Minute,
+				},
+				SplitSubtests: time.Minute,
+			},
+			tests:      []Test{{Package: "pkg/a", Name: "Test1"}},
+			target:     2 * time.Minute,
+			wantChunks: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tests := tests
+			if tc.tests != nil {
+				tests = tc.tests
+			}
+			got, err := tc.chunker.ChunksWithin(tests, tc.target)
+			if err != nil {
+				t.Fatalf("Chunker.ChunksWithin() error = %v", err)
+			}
+			if len(got) != tc.wantChunks {
+				t.Errorf("Chunker.ChunksWithin() returned %d chunks, want %d", len(got), tc.wantChunks)
+			}
+		})
+	}
+
+	if _, err := (&Chunker{}).ChunksWithin(tests, 0); err == nil {
+		t.Error("Chunker.ChunksWithin() with zero target expected error")
+	}
+}
+
+func TestUnits(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/b", Name: "Test3"},
+		{Package: "pkg/a", Name: "Test2"},
+		{Package: "pkg/a", Name: "Test1"},
+	}
+
+	units, err := Units(tests, GranularityPackage)
+	if err != nil {
+		t.Fatalf("Units() error = %v", err)
+	}
+
+	want := []Unit{
+		{Key: "pkg/a", Tests: []Test{{Package: "pkg/a", Name: "Test1"}, {Package: "pkg/a", Name: "Test2"}}},
+		{Key: "pkg/b", Tests: []Test{{Package: "pkg/b", Name: "Test3"}}},
+	}
+	if !reflect.DeepEqual(units, want) {
+		t.Errorf("Units() = %v, want %v", units, want)
+	}
+
+	// Tests without a file are grouped by package
+	tests = []Test{
+		{Package: "pkg/a", Name: "Test1", File: "pkg/a/b_test.go"},
+		{Package: "pkg/a", Name: "Test2", File: "pkg/a/a_test.go"},
+		{Package: "pkg/a", Name: "Test3", File: "pkg/a/b_test.go"},
+		{Package: "pkg/b", Name: "Test4"},
+	}
+	units, err = Units(tests, GranularityFile)
+	if err != nil {
+		t.Fatalf("Units() error = %v", err)
+	}
+
+	want = []Unit{
+		{Key: "pkg/a/b_test.go", Tests: []Test{tests[0], tests[2]}},
+		{Key: "pkg/a/a_test.go", Tests: []Test{tests[1]}},
+		{Key: "pkg/b", Tests: []Test{tests[3]}},
+	}
+	if !reflect.DeepEqual(units, want) {
+		t.Errorf("Units() = %v, want %v", units, want)
+	}
+
+	if _, err := Units(tests, "module"); err == nil {
+		t.Error("Units() expected error for unknown granularity")
+	}
+}
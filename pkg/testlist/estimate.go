@@ -0,0 +1,315 @@
+package testlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTestTime is the estimated duration of a test without timing data
+// when there is nothing better to go on
+const DefaultTestTime = time.Second
+
+// typicalCost is the cost of a test that is expected to take
+// DefaultTestTime, when there are no timings to calibrate against
+const typicalCost = 20
+
+// subtestCost is how many lines of a test function a call to Run counts as
+// when estimating statically, as subtests often run in a loop over cases
+const subtestCost = 10
+
+// Estimator estimates the duration of tests that have no timing data
+type Estimator interface {
+	Estimate(test Test) time.Duration
+}
+
+// EstimatorFunc adapts a function to the Estimator interface
+type EstimatorFunc func(test Test) time.Duration
+
+// Estimate calls f(test)
+func (f EstimatorFunc) Estimate(test Test) time.Duration {
+	return f(test)
+}
+
+// Estimators are the names accepted by NewEstimator
+var Estimators = []string{"default", "package-median", "suite-median", "static"}
+
+// NewEstimator returns the named estimator, calibrated against the timings
+// of top-level tests. An empty name returns the default estimator, which
+// uses DefaultTestTime.
+func NewEstimator(name string, timings map[string]time.Duration) (Estimator, error) {
+	timings = topLevelTimings(timings)
+	switch name {
+	case "", "default":
+		return EstimatorFunc(func(Test) time.Duration { return DefaultTestTime }), nil
+	case "package-median":
+		return newPackageMedian(timings), nil
+	case "suite-median":
+		median := medianOr(durationsOf(timings), DefaultTestTime)
+		return EstimatorFunc(func(Test) time.Duration { return median }), nil
+	case "static":
+		return &staticEstimator{timings: timings}, nil
+	default:
+		return nil, fmt.Errorf("unknown estimator: %s (available: %s)", name, strings.Join(Estimators, ", "))
+	}
+}
+
+// newPackageMedian estimates tests with the median duration of the other
+// tests in their package, or of the whole suite for packages without timings
+func newPackageMedian(timings map[string]time.Duration) Estimator {
+	byPackage := make(map[string][]time.Duration)
+	for key, d := range timings {
+		pkg, _ := splitKey(key)
+		byPackage[pkg] = append(byPackage[pkg], d)
+	}
+
+	suite := medianOr(durationsOf(timings), DefaultTestTime)
+	medians := make(map[string]time.Duration, len(byPackage))
+	for pkg, durations := range byPackage {
+		medians[pkg] = medianOr(durations, suite)
+	}
+
+	return EstimatorFunc(func(test Test) time.Duration {
+		if d, ok := medians[test.Package]; ok {
+			return d
+		}
+		return suite
+	})
+}
+
+// staticEstimator estimates tests by the size of their function and the
+// number of subtests it runs, scaled by how long timed tests take per line.
+// Estimates only depend on the source and timings, so every shard agrees.
+type staticEstimator struct {
+	timings map[string]time.Duration
+
+	once    sync.Once
+	modules []goModule
+	root    string        // Directory of the module containing the working directory
+	perCost time.Duration // Estimated duration per unit of cost
+	unknown time.Duration // Estimate for tests whose source isn't found
+
+	mu    sync.Mutex
+	costs map[string]map[string]int // Package to test name to cost
+}
+
+// Estimate returns the estimated duration of a test
+func (e *staticEstimator) Estimate(test Test) time.Duration {
+	e.once.Do(e.calibrate)
+
+	cost, ok := e.cost(test)
+	if !ok {
+		return e.unknown
+	}
+	return e.perCost * time.Duration(cost)
+}
+
+// calibrate finds the median duration per unit of cost across timed tests
+func (e *staticEstimator) calibrate() {
+	e.modules, e.root = listModules()
+
+	var rates []time.Duration
+	for key, d := range e.timings {
+		pkg, name := splitKey(key)
+		if cost, ok := e.cost(Test{Package: pkg, Name: name}); ok {
+			rates = append(rates, d/time.Duration(cost))
+		}
+	}
+
+	// Without timings, a test of typical size gets the default duration
+	e.perCost = medianOr(rates, DefaultTestTime/typicalCost)
+	e.unknown = medianOr(durationsOf(e.timings), DefaultTestTime)
+}
+
+// cost returns the cost of a test function, parsing its package on first use
+func (e *staticEstimator) cost(test Test) (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.costs == nil {
+		e.costs = make(map[string]map[string]int)
+	}
+	costs, ok := e.costs[test.Package]
+	if !ok {
+		costs = parseCosts(e.packageDir(test.Package))
+		e.costs[test.Package] = costs
+	}
+
+	cost, ok := costs[test.Name]
+	return cost, ok
+}
+
+// packageDir returns the directory of a package, which is either an import
+// path within one of the listed modules or relative to the module root
+func (e *staticEstimator) packageDir(pkg string) string {
+	for _, m := range e.modules {
+		if pkg == m.Path {
+			return m.Dir
+		}
+		if rest, ok := strings.CutPrefix(pkg, m.Path+"/"); ok {
+			return filepath.Join(m.Dir, filepath.FromSlash(rest))
+		}
+	}
+	if e.root == "" {
+		return ""
+	}
+	return filepath.Join(e.root, filepath.FromSlash(pkg))
+}
+
+// goModule is a module as printed by go list -m -json
+type goModule struct {
+	Path string
+	Dir  string
+}
+
+// listModules returns the main modules, which are several under a go.work
+// file, and the directory of the innermost one containing the working
+// directory
+func listModules() ([]goModule, string) {
+	out, err := exec.Command("go", "list", "-m", "-json").Output()
+	if err != nil {
+		return nil, ""
+	}
+
+	var modules []goModule
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var m goModule
+		if err := dec.Decode(&m); err != nil {
+			break
+		}
+		modules = append(modules, m)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return modules, ""
+	}
+	var root string
+	for _, m := range modules {
+		rel, err := filepath.Rel(m.Dir, wd)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(m.Dir) > len(root) {
+			root = m.Dir
+		}
+	}
+	return modules, root
+}
+
+// parseCosts returns the cost of each test function in a directory's test
+// files, regardless of build constraints
+func parseCosts(dir string) map[string]int {
+	costs := make(map[string]int)
+	if dir == "" {
+		return costs
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Body == nil {
+				continue
+			}
+			if _, ok := KindOf(fn.Name.Name); !ok {
+				continue
+			}
+
+			lines := fset.Position(fn.End()).Line - fset.Position(fn.Pos()).Line + 1
+			costs[fn.Name.Name] = lines + subtestCost*countRuns(fn.Body)
+		}
+	}
+	return costs
+}
+
+// countRuns returns the number of calls to a Run method, such as t.Run
+func countRuns(body *ast.BlockStmt) int {
+	var n int
+	ast.Inspect(body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Run" {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+// splitKey splits a timing key into its package and test name. Package
+// paths and subtest names can both contain dots, so the name starts after
+// the first dot that is followed by a top-level test name, which has
+// neither dots nor slashes.
+func splitKey(key string) (string, string) {
+	for i := 0; i < len(key); i++ {
+		if key[i] != '.' {
+			continue
+		}
+		top, _, _ := strings.Cut(key[i+1:], "/")
+		if _, ok := KindOf(top); ok && !strings.Contains(top, ".") {
+			return key[:i], key[i+1:]
+		}
+	}
+
+	i := strings.LastIndex(key, ".")
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+// topLevelTimings returns the timings of top-level tests, as subtests
+// would skew medians that estimate whole test functions
+func topLevelTimings(timings map[string]time.Duration) map[string]time.Duration {
+	top := make(map[string]time.Duration, len(timings))
+	for key, d := range timings {
+		if _, name := splitKey(key); !strings.Contains(name, "/") {
+			top[key] = d
+		}
+	}
+	return top
+}
+
+// durationsOf returns the values of a timing map
+func durationsOf(timings map[string]time.Duration) []time.Duration {
+	durations := make([]time.Duration, 0, len(timings))
+	for _, d := range timings {
+		durations = append(durations, d)
+	}
+	return durations
+}
+
+// medianOr returns the median of durations, or fallback if there are none
+func medianOr(durations []time.Duration, fallback time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return fallback
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
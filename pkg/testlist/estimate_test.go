@@ -0,0 +1,172 @@
+package testlist
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewEstimator(t *testing.T) {
+	timings := map[string]time.Duration{
+		"pkg/a.TestA": 1 * time.Millisecond,
+		"pkg/a.TestB": 3 * time.Millisecond,
+		"pkg/a.TestC": 8 * time.Millisecond,
+		"pkg/b.TestD": 10 * time.Second,
+		"pkg/b.TestE": 20 * time.Second,
+	}
+
+	tests := []struct {
+		name      string
+		estimator string
+		timings   map[string]time.Duration
+		test      Test
+		want      time.Duration
+	}{
+		{
+			name:      "default",
+			estimator: "default",
+			timings:   timings,
+			test:      Test{Package: "pkg/a", Name: "TestNew"},
+			want:      DefaultTestTime,
+		},
+		{
+			name:      "empty name is default",
+			estimator: "",
+			timings:   timings,
+			test:      Test{Package: "pkg/a", Name: "TestNew"},
+			want:      DefaultTestTime,
+		},
+		{
+			name:      "package median",
+			estimator: "package-median",
+			timings:   timings,
+			test:      Test{Package: "pkg/a", Name: "TestNew"},
+			want:      3 * time.Millisecond,
+		},
+		{
+			name:      "package median with even count",
+			estimator: "package-median",
+			timings:   timings,
+			test:      Test{Package: "pkg/b", Name: "TestNew"},
+			want:      15 * time.Second,
+		},
+		{
+			name:      "package median falls back to suite median",
+			estimator: "package-median",
+			timings:   timings,
+			test:      Test{Package: "pkg/c", Name: "TestNew"},
+			want:      8 * time.Millisecond,
+		},
+		{
+			name:      "package median ignores subtests",
+			estimator: "package-median",
+			timings: map[string]time.Duration{
+				"pkg/a.TestA":        1 * time.Millisecond,
+				"pkg/a.TestA/case.1": 1 * time.Minute,
+				"pkg/a.TestA/case.2": 1 * time.Minute,
+			},
+			test: Test{Package: "pkg/a", Name: "TestNew"},
+			want: 1 * time.Millisecond,
+		},
+		{
+			name:      "suite median",
+			estimator: "suite-median",
+			timings:   timings,
+			test:      Test{Package: "pkg/b", Name: "TestNew"},
+			want:      8 * time.Millisecond,
+		},
+		{
+			name:      "suite median without timings",
+			estimator: "suite-median",
+			test:      Test{Package: "pkg/b", Name: "TestNew"},
+			want:      DefaultTestTime,
+		},
+		{
+			name:      "static calibrated by timed tests",
+			estimator: "static",
+			timings: map[string]time.Duration{
+				"pkg/testlist/testdata/estimate.TestShort": 3 * time.Millisecond,
+			},
+			// 8 lines and 2 calls to t.Run at 1ms per line
+			test: Test{Package: "pkg/testlist/testdata/estimate", Name: "TestSubtests"},
+			want: 28 * time.Millisecond,
+		},
+		{
+			name:      "static without timings",
+			estimator: "static",
+			test:      Test{Package: "pkg/testlist/testdata/estimate", Name: "TestShort"},
+			want:      3 * DefaultTestTime / typicalCost,
+		},
+		{
+			name:      "static without source",
+			estimator: "static",
+			timings:   timings,
+			test:      Test{Package: "pkg/missing", Name: "TestNew"},
+			want:      8 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimator, err := NewEstimator(tt.estimator, tt.timings)
+			if err != nil {
+				t.Fatalf("NewEstimator() error = %v", err)
+			}
+			if got := estimator.Estimate(tt.test); got != tt.want {
+				t.Errorf("Estimate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewEstimator("magic", nil); err == nil {
+		t.Error("NewEstimator() with unknown name expected error")
+	}
+}
+
+func TestSplitKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		wantPkg  string
+		wantName string
+	}{
+		{key: "pkg/a.TestA", wantPkg: "pkg/a", wantName: "TestA"},
+		{key: "pkg/a.TestA/case.1", wantPkg: "pkg/a", wantName: "TestA/case.1"},
+		{key: "pkg/a.TestA/v1.2/x", wantPkg: "pkg/a", wantName: "TestA/v1.2/x"},
+		{key: "github.com/x/y.TestA", wantPkg: "github.com/x/y", wantName: "TestA"},
+		{key: "gopkg.in/yaml.v3.TestA/a.b", wantPkg: "gopkg.in/yaml.v3", wantName: "TestA/a.b"},
+		{key: "github.com/x.BenchmarkA", wantPkg: "github.com/x", wantName: "BenchmarkA"},
+		{key: "pkg/a.Helper", wantPkg: "pkg/a", wantName: "Helper"},
+		{key: "TestA", wantPkg: "", wantName: "TestA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			pkg, name := splitKey(tt.key)
+			if pkg != tt.wantPkg || name != tt.wantName {
+				t.Errorf("splitKey() = %q, %q, want %q, %q", pkg, name, tt.wantPkg, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestChunkerEstimator(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "TestA"},
+		{Package: "pkg/a", Name: "TestB"},
+		{Package: "pkg/a", Name: "TestNew"},
+	}
+	timings := map[string]time.Duration{
+		"pkg/a.TestA": 10 * time.Millisecond,
+		"pkg/a.TestB": 30 * time.Millisecond,
+	}
+
+	estimator, err := NewEstimator("package-median", timings)
+	if err != nil {
+		t.Fatalf("NewEstimator() error = %v", err)
+	}
+
+	// Without an estimator, the new test would dwarf the others
+	chunker := &Chunker{Timings: timings, Estimator: estimator}
+	if got := chunker.Estimate(tests); got != 60*time.Millisecond {
+		t.Errorf("Chunker.Estimate() = %v, want 60ms", got)
+	}
+}
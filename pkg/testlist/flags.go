@@ -0,0 +1,56 @@
+package testlist
+
+import "strings"
+
+// buildFlags are the go build flags that can change which packages, files
+// and tests are seen during discovery. The value is true for flags that take
+// an argument, which may be given as either -flag=value or -flag value.
+var buildFlags = map[string]bool{
+	"asan":          false,
+	"asmflags":      true,
+	"buildvcs":      false,
+	"compiler":      true,
+	"gccgoflags":    true,
+	"gcflags":       true,
+	"installsuffix": true,
+	"ldflags":       true,
+	"mod":           true,
+	"modfile":       true,
+	"msan":          false,
+	"overlay":       true,
+	"pgo":           true,
+	"race":          false,
+	"tags":          true,
+	"toolexec":      true,
+	"trimpath":      false,
+}
+
+// BuildFlags returns the subset of go test arguments that are build flags
+// relevant to test discovery, such as -tags, -race and -mod. Anything after
+// -args is passed to the test binary by go test, so is ignored.
+func BuildFlags(args []string) []string {
+	var flags []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		name, _, hasValue := strings.Cut(name, "=")
+		if name == "args" {
+			break
+		}
+		takesValue, ok := buildFlags[name]
+		if !ok {
+			continue
+		}
+
+		flags = append(flags, arg)
+		if takesValue && !hasValue && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return flags
+}
@@ -0,0 +1,54 @@
+package testlist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "no args",
+			args: nil,
+			want: nil,
+		},
+		{
+			name: "tags with equals",
+			args: []string{"-v", "-tags=integration,e2e", "-count=1"},
+			want: []string{"-tags=integration,e2e"},
+		},
+		{
+			name: "tags with separate value",
+			args: []string{"-tags", "integration", "-timeout", "10m"},
+			want: []string{"-tags", "integration"},
+		},
+		{
+			name: "boolean flags",
+			args: []string{"-race", "-short", "--trimpath"},
+			want: []string{"-race", "--trimpath"},
+		},
+		{
+			name: "module flags",
+			args: []string{"-mod=vendor", "-modfile", "go.test.mod", "-run", "TestFoo"},
+			want: []string{"-mod=vendor", "-modfile", "go.test.mod"},
+		},
+		{
+			name: "stops at test binary args",
+			args: []string{"--", "-race", "-args", "-tags=ignored"},
+			want: []string{"-race"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildFlags(tt.args)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildFlags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
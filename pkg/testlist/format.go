@@ -2,6 +2,7 @@ package testlist
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -24,19 +25,47 @@ func Format(tests []Test, format string) (string, error) {
 		}
 		return strings.Join(paths, "\n"), nil
 
-	case "runPattern":
-		// Create go test -run pattern
-		var testNames []string
+	case "listFiles":
+		// Join the unique files defining the tests, sorted
+		var files []string
+		seen := make(map[string]bool)
+		for _, test := range tests {
+			if test.File == "" {
+				return "", fmt.Errorf("no file is known for %s", test)
+			}
+			if !seen[test.File] {
+				seen[test.File] = true
+				files = append(files, test.File)
+			}
+		}
+		sort.Strings(files)
+		return strings.Join(files, "\n"), nil
+
+	case "runPattern", "benchPattern":
+		// A single pattern can't select part of a test
 		for _, test := range tests {
-			parts := strings.Split(test.String(), ".")
-			if len(parts) == 2 {
-				testNames = append(testNames, parts[1])
+			if test.split() {
+				return "", fmt.Errorf("%s can't select %s, which is split by subtest", format, test)
 			}
 		}
+	}
+
+	switch format {
+	case "runPattern":
+		// Create go test -run pattern
+		testNames, _ := splitNames(tests)
 		if len(testNames) == 0 {
 			return "", nil
 		}
-		return fmt.Sprintf("^(%s)$", strings.Join(testNames, "|")), nil
+		return namePattern(testNames), nil
+
+	case "benchPattern":
+		// Create go test -bench pattern
+		_, benchNames := splitNames(tests)
+		if len(benchNames) == 0 {
+			return "", nil
+		}
+		return namePattern(benchNames), nil
 
 	default:
 		return "", fmt.Errorf("unknown format: %s", format)
@@ -42,12 +42,54 @@ func TestFormat(t *testing.T) {
 			tests:    []Test{},
 			expected: "",
 		},
+		{
+			name:   "listFiles format",
+			format: "listFiles",
+			tests: []Test{
+				{Package: "pkg/example", Name: "TestOne", File: "pkg/example/one_test.go"},
+				{Package: "pkg/example", Name: "TestTwo", File: "pkg/example/a_test.go"},
+				{Package: "pkg/example", Name: "TestThree", File: "pkg/example/one_test.go"},
+			},
+			expected: "pkg/example/a_test.go\npkg/example/one_test.go",
+		},
+		{
+			name:    "listFiles without files",
+			format:  "listFiles",
+			tests:   tests,
+			wantErr: true,
+		},
+		{
+			name:   "runPattern can't select split tests",
+			format: "runPattern",
+			tests: []Test{
+				{Package: "pkg/example", Name: "TestTable/a", Kind: KindTest},
+			},
+			wantErr: true,
+		},
 		{
 			name:    "unknown format",
 			format:  "invalid",
 			tests:   tests,
 			wantErr: true,
 		},
+		{
+			name:   "runPattern excludes benchmarks",
+			format: "runPattern",
+			tests: []Test{
+				{Package: "pkg/example", Name: "TestOne", Kind: KindTest},
+				{Package: "pkg/example", Name: "BenchmarkOne", Kind: KindBenchmark},
+			},
+			expected: "^(TestOne)$",
+		},
+		{
+			name:   "benchPattern format",
+			format: "benchPattern",
+			tests: []Test{
+				{Package: "pkg/example", Name: "TestOne", Kind: KindTest},
+				{Package: "pkg/example", Name: "BenchmarkOne", Kind: KindBenchmark},
+			},
+			expected: "^(BenchmarkOne)$",
+		},
 		{
 			name:     "single test",
 			format:   "runPattern",
@@ -0,0 +1,175 @@
+package testlist
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sort"
+	"time"
+)
+
+// hashTolerance is how far above the mean duration a chunk may be before the
+// hash strategy moves units out of it
+const hashTolerance = 0.1
+
+// chunkByHash assigns units to chunks with rendezvous hashing: each unit goes
+// to the chunk with the highest hash of its key and the chunk index. A unit's
+// chunk depends only on its own key and the number of chunks, so adding or
+// removing tests doesn't move any other tests.
+//
+// When timings are available a balancing pass follows, moving the longest
+// units out of any chunk that is more than hashTolerance above the mean into
+// their next preferred chunk with room. This trades a little stability for
+// balance.
+//
+// With capacities, scores are weighted so that each chunk receives units in
+// proportion to its capacity, and the balancing pass limits each chunk to
+// its share of the mean.
+func chunkByHash(in Input) [][]Test {
+	assigned := make([]int, len(in.Units))
+	prefs := make([][]int, len(in.Units))
+	for i, unit := range in.Units {
+		if in.Capacities != nil {
+			prefs[i] = weightedRendezvous(unit.Key, in)
+		} else {
+			prefs[i] = rendezvous(unit.Key, in.Total)
+		}
+		assigned[i] = prefs[i][0]
+	}
+
+	if in.Timed && len(in.Units) > 0 {
+		balanceHashed(in, assigned, prefs)
+	}
+
+	chunks := make([][]Test, in.Total)
+	for i, unit := range in.Units {
+		chunks[assigned[i]] = append(chunks[assigned[i]], unit.Tests...)
+	}
+	return chunks
+}
+
+// balanceHashed moves units out of overloaded chunks, updating assigned. A
+// package's overhead is paid once by each chunk that runs it.
+func balanceHashed(in Input, assigned []int, prefs [][]int) {
+	times, total := in.Weights, in.Total
+	loads := make([]time.Duration, total)
+	counts := make([]map[string]int, total) // Units of each package in each chunk
+	for chunk := range counts {
+		counts[chunk] = make(map[string]int)
+	}
+
+	// added returns how much a unit adds to a chunk, and removed how much
+	// taking it out saves, including its package's overhead if it is the
+	// chunk's only unit of the package
+	added := func(chunk, u int) time.Duration {
+		if counts[chunk][in.pkg(u)] > 0 {
+			return times[u]
+		}
+		return times[u] + in.overhead(u)
+	}
+	removed := func(chunk, u int) time.Duration {
+		if counts[chunk][in.pkg(u)] > 1 {
+			return times[u]
+		}
+		return times[u] + in.overhead(u)
+	}
+
+	var sum, longest time.Duration
+	for u := range times {
+		load := added(assigned[u], u)
+		loads[assigned[u]] += load
+		counts[assigned[u]][in.pkg(u)]++
+		sum += load
+		longest = max(longest, times[u]+in.overhead(u))
+	}
+
+	// A chunk can always hold the longest unit, however unbalanced
+	limits := make([]time.Duration, total)
+	for chunk := range limits {
+		limit := time.Duration(float64(sum) / float64(total) * in.share(chunk) * (1 + hashTolerance))
+		limits[chunk] = max(limit, longest)
+	}
+
+	// Consider the longest units first, so as few units as possible move
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return times[order[a]] > times[order[b]]
+	})
+
+	for chunk := 0; chunk < total; chunk++ {
+		for _, u := range order {
+			if loads[chunk] <= limits[chunk] {
+				break
+			}
+			if assigned[u] != chunk {
+				continue
+			}
+			for _, dest := range prefs[u] {
+				if dest != chunk && loads[dest]+added(dest, u) <= limits[dest] {
+					loads[chunk] -= removed(chunk, u)
+					counts[chunk][in.pkg(u)]--
+					loads[dest] += added(dest, u)
+					counts[dest][in.pkg(u)]++
+					assigned[u] = dest
+					break
+				}
+			}
+		}
+	}
+}
+
+// rendezvous returns chunk indexes ordered by preference for the given key
+func rendezvous(key string, total int) []int {
+	scores := make([]uint64, total)
+	prefs := make([]int, total)
+	for i := range prefs {
+		prefs[i] = i
+		scores[i] = rendezvousScore(key, i)
+	}
+	sort.SliceStable(prefs, func(a, b int) bool {
+		return scores[prefs[a]] > scores[prefs[b]]
+	})
+	return prefs
+}
+
+// weightedRendezvous returns chunk indexes ordered by preference for the
+// given key, where each chunk is preferred in proportion to its share
+func weightedRendezvous(key string, in Input) []int {
+	scores := make([]float64, in.Total)
+	prefs := make([]int, in.Total)
+	for i := range prefs {
+		prefs[i] = i
+		// Map the hash into (0, 1), so -share/ln(h) is positive and a chunk
+		// with twice the share wins twice as often
+		h := (float64(rendezvousScore(key, i)>>11) + 0.5) / (1 << 53)
+		scores[i] = -in.share(i) / math.Log(h)
+	}
+	sort.SliceStable(prefs, func(a, b int) bool {
+		return scores[prefs[a]] > scores[prefs[b]]
+	})
+	return prefs
+}
+
+// rendezvousScore hashes a key together with a chunk index
+func rendezvousScore(key string, chunk int) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(chunk))
+	_, _ = h.Write(buf[:])
+	return mix64(h.Sum64())
+}
+
+// mix64 is the splitmix64 finalizer, which spreads FNV's output more evenly
+// between chunks
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,116 @@
+package testlist
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// chunkIndexes returns the chunk each test was assigned to
+func chunkIndexes(chunks [][]Test) map[string]int {
+	indexes := make(map[string]int)
+	for i, chunk := range chunks {
+		for _, test := range chunk {
+			indexes[test.String()] = i
+		}
+	}
+	return indexes
+}
+
+func generateTests(n int) []Test {
+	tests := make([]Test, n)
+	for i := range tests {
+		tests[i] = Test{Package: fmt.Sprintf("pkg/p%d", i%7), Name: fmt.Sprintf("Test%03d", i)}
+	}
+	return tests
+}
+
+func TestChunkByHashStable(t *testing.T) {
+	chunker := &Chunker{Strategy: StrategyFunc(chunkByHash)}
+
+	before, err := chunker.Chunks(generateTests(200), 4)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+
+	// Add a test that sorts before every other test
+	tests := append(generateTests(200), Test{Package: "pkg/a", Name: "TestAdded"})
+	after, err := chunker.Chunks(tests, 4)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+
+	beforeIndexes, afterIndexes := chunkIndexes(before), chunkIndexes(after)
+	if len(afterIndexes) != len(beforeIndexes)+1 {
+		t.Fatalf("Chunker.Chunks() assigned %d tests, want %d", len(afterIndexes), len(beforeIndexes)+1)
+	}
+	for test, index := range beforeIndexes {
+		if afterIndexes[test] != index {
+			t.Errorf("test %s moved from chunk %d to %d", test, index, afterIndexes[test])
+		}
+	}
+
+	for i, chunk := range after {
+		if len(chunk) == 0 {
+			t.Errorf("chunk %d is empty", i)
+		}
+	}
+}
+
+func TestChunkByHashBalanced(t *testing.T) {
+	tests := generateTests(40)
+	timings := make(map[string]time.Duration)
+	for i, test := range tests {
+		timings[test.String()] = time.Duration(i%5+1) * 100 * time.Millisecond
+	}
+
+	chunker := &Chunker{Strategy: StrategyFunc(chunkByHash), Timings: timings}
+	chunks, err := chunker.Chunks(tests, 3)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+
+	var sum time.Duration
+	for _, d := range timings {
+		sum += d
+	}
+	limit := time.Duration(float64(sum) / 3 * (1 + hashTolerance))
+
+	count := 0
+	for i, chunk := range chunks {
+		var load time.Duration
+		for _, test := range chunk {
+			load += timings[test.String()]
+		}
+		if load > limit {
+			t.Errorf("chunk %d load %v exceeds limit %v", i, load, limit)
+		}
+		count += len(chunk)
+	}
+	if count != len(tests) {
+		t.Errorf("Chunker.Chunks() assigned %d tests, want %d", count, len(tests))
+	}
+}
+
+func TestChunkByHashOverheads(t *testing.T) {
+	var tests []Test
+	timings := make(map[string]time.Duration)
+	overheads := make(map[string]time.Duration)
+	for i := 0; i < 2000; i++ {
+		test := Test{Package: fmt.Sprintf("pkg/p%d", i%100), Name: fmt.Sprintf("Test%04d", i)}
+		tests = append(tests, test)
+		timings[test.String()] = time.Duration(i*7919%2000) * time.Millisecond
+		overheads[test.Package] = 2 * time.Second
+	}
+
+	// Chunks pay the overhead of every package they run, which the
+	// balancing pass must count to keep chunks within the tolerance
+	chunker := &Chunker{Strategy: StrategyFunc(chunkByHash), Timings: timings, Overheads: overheads}
+	chunks, err := chunker.Chunks(tests, 50)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+	if got := chunker.Balance(chunks).Imbalance; got > hashTolerance+0.01 {
+		t.Errorf("imbalance = %.3f, want at most %.3f", got, hashTolerance)
+	}
+}
@@ -0,0 +1,310 @@
+package testlist
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Invocation is a single go test run covering some of a chunk's tests
+type Invocation struct {
+	Flags    []string // Flags selecting tests, such as -run and -bench
+	Packages []string // Packages to test, relative to the module root
+}
+
+// Args returns the go test arguments for the invocation
+func (inv Invocation) Args() []string {
+	args := append([]string{}, inv.Flags...)
+	for _, pkg := range inv.Packages {
+		args = append(args, "./"+pkg)
+	}
+	return args
+}
+
+// InvocationOptions controls how tests are turned into go test invocations
+type InvocationOptions struct {
+	// FuzzTime fuzzes each fuzz target for this long with -fuzz. When empty,
+	// only the seed corpus of each fuzz target is run.
+	FuzzTime string
+
+	// All is every discovered test, across all chunks. It is used to decide
+	// which packages can safely share an invocation. When nil, each package
+	// is run in an invocation of its own.
+	All []Test
+
+	// MaxPatternLength is the longest pattern passed to -run or -bench before
+	// tests are split across multiple invocations. Zero means no limit.
+	MaxPatternLength int
+
+	// Exclusive returns whether a test must run on its own. Each exclusive
+	// test gets an invocation of its own, after the other tests.
+	Exclusive func(Test) bool
+}
+
+// Invocations returns the go test invocations needed to run the given tests.
+// Tests, examples and fuzz seed corpora are selected with -run and benchmarks
+// with -bench. Fuzzing is limited by go test to a single target in a single
+// package, so each fuzz target gets an invocation of its own, as does each
+// exclusive test. Exclusive tests split by subtest run after the others, in
+// the invocations they would get anyway.
+//
+// Patterns apply to every package in an invocation, so packages are only
+// grouped together when the combined pattern can't select a test that belongs
+// to another chunk. This ensures each test runs in exactly one chunk.
+func Invocations(tests []Test, opts InvocationOptions) []Invocation {
+	var invocations []Invocation
+	var runTests, splitTests, exclusiveTests, exclusiveSplits, fuzzTests []Test
+	for _, test := range tests {
+		switch {
+		case test.Kind == KindFuzz && opts.FuzzTime != "":
+			fuzzTests = append(fuzzTests, test)
+		case opts.Exclusive != nil && opts.Exclusive(test):
+			if test.split() {
+				exclusiveSplits = append(exclusiveSplits, test)
+			} else {
+				exclusiveTests = append(exclusiveTests, test)
+			}
+		case test.split():
+			splitTests = append(splitTests, test)
+		default:
+			runTests = append(runTests, test)
+		}
+	}
+
+	for _, group := range groupPackages(runTests, opts.All) {
+		for _, batch := range splitPatterns(group, opts.MaxPatternLength) {
+			invocations = append(invocations, Invocation{
+				Flags:    selectFlags(batch),
+				Packages: Packages(batch),
+			})
+		}
+	}
+
+	invocations = append(invocations, splitInvocations(splitTests)...)
+
+	for _, test := range exclusiveTests {
+		invocations = append(invocations, Invocation{
+			Flags:    selectFlags([]Test{test}),
+			Packages: []string{test.Package},
+		})
+	}
+	invocations = append(invocations, splitInvocations(exclusiveSplits)...)
+
+	for _, test := range fuzzTests {
+		pattern := namePattern([]string{test.Name})
+		invocations = append(invocations, Invocation{
+			Flags:    []string{"-run=" + pattern, "-fuzz=" + pattern, "-fuzztime=" + opts.FuzzTime},
+			Packages: []string{test.Package},
+		})
+	}
+
+	return invocations
+}
+
+// splitInvocations returns invocations for the parts of tests split by
+// subtest. A -run pattern's subtest level applies to every test it selects,
+// so each split test needs invocations of its own: one selecting its
+// subtests in this chunk, and one running the rest of the test, skipping the
+// subtests that run separately.
+func splitInvocations(tests []Test) []Invocation {
+	type split struct {
+		test     Test
+		subtests []string
+		rest     *Test
+	}
+
+	var splits []*split
+	index := make(map[string]*split)
+	for _, test := range tests {
+		key := test.Package + "." + test.topLevel()
+		s, ok := index[key]
+		if !ok {
+			s = &split{test: Test{Package: test.Package, Name: test.topLevel(), Kind: test.Kind}}
+			index[key] = s
+			splits = append(splits, s)
+		}
+		if test.Skip != "" {
+			rest := test
+			s.rest = &rest
+		} else {
+			_, name, _ := strings.Cut(test.Name, "/")
+			s.subtests = append(s.subtests, name)
+		}
+	}
+
+	var invocations []Invocation
+	for _, s := range splits {
+		pattern := namePattern([]string{s.test.Name})
+		if len(s.subtests) > 0 {
+			invocations = append(invocations, Invocation{
+				Flags:    []string{"-run=" + pattern + "/" + namePattern(s.subtests)},
+				Packages: []string{s.test.Package},
+			})
+		}
+		if s.rest != nil {
+			invocations = append(invocations, Invocation{
+				Flags:    []string{"-run=" + pattern, "-skip=" + pattern + "/" + s.rest.Skip},
+				Packages: []string{s.test.Package},
+			})
+		}
+	}
+	return invocations
+}
+
+// groupPackages splits tests into groups that can share one set of patterns.
+// A package can join a group when none of the names selected by the group
+// match a test in the package that isn't in tests, and vice versa.
+func groupPackages(tests []Test, all []Test) [][]Test {
+	selected := namesByPackage(tests)
+	existing := namesByPackage(all)
+
+	byPackage := make(map[string][]Test)
+	for _, test := range tests {
+		byPackage[test.Package] = append(byPackage[test.Package], test)
+	}
+
+	// conflicts returns whether names select unselected tests in pkg
+	conflicts := func(names map[string]bool, pkg string) bool {
+		for name := range names {
+			if existing[pkg][name] && !selected[pkg][name] {
+				return true
+			}
+		}
+		return false
+	}
+
+	type group struct {
+		packages []string
+		names    map[string]bool
+		tests    []Test
+	}
+
+	var groups []*group
+	for _, pkg := range Packages(tests) {
+		var target *group
+		if all != nil {
+			for _, g := range groups {
+				if conflicts(g.names, pkg) {
+					continue
+				}
+				ok := true
+				for _, other := range g.packages {
+					if conflicts(selected[pkg], other) {
+						ok = false
+						break
+					}
+				}
+				if ok {
+					target = g
+					break
+				}
+			}
+		}
+		if target == nil {
+			target = &group{names: make(map[string]bool)}
+			groups = append(groups, target)
+		}
+
+		target.packages = append(target.packages, pkg)
+		for name := range selected[pkg] {
+			target.names[name] = true
+		}
+		target.tests = append(target.tests, byPackage[pkg]...)
+	}
+
+	result := make([][]Test, len(groups))
+	for i, g := range groups {
+		result[i] = g.tests
+	}
+	return result
+}
+
+// splitPatterns splits tests into batches whose patterns are no longer than
+// maxLength. Batches are split by name rather than by test, so a name that is
+// selected in several packages only runs in a single batch.
+func splitPatterns(tests []Test, maxLength int) [][]Test {
+	if maxLength <= 0 {
+		return [][]Test{tests}
+	}
+
+	// Patterns look like ^(a|b)$, so start with the surrounding characters
+	// less the separator that the first name doesn't need
+	const emptyLength = len("^()$") - 1
+
+	batchOf := make(map[string]int)
+	var batches [][]Test
+	length := 0
+	for _, test := range tests {
+		batch, ok := batchOf[test.Name]
+		if !ok {
+			// Each name adds its escaped form plus a separator to the pattern
+			nameLength := len(regexp.QuoteMeta(test.Name)) + 1
+			if len(batches) == 0 || length+nameLength > maxLength {
+				batches = append(batches, nil)
+				length = emptyLength
+			}
+			batch = len(batches) - 1
+			batchOf[test.Name] = batch
+			length += nameLength
+		}
+		batches[batch] = append(batches[batch], test)
+	}
+
+	return batches
+}
+
+// namesByPackage returns the set of test names in each package
+func namesByPackage(tests []Test) map[string]map[string]bool {
+	names := make(map[string]map[string]bool)
+	for _, test := range tests {
+		if names[test.Package] == nil {
+			names[test.Package] = make(map[string]bool)
+		}
+		names[test.Package][test.Name] = true
+	}
+	return names
+}
+
+// selectFlags returns the -run and -bench flags that select the given tests
+func selectFlags(tests []Test) []string {
+	runNames, benchNames := splitNames(tests)
+
+	var flags []string
+	if len(runNames) > 0 {
+		flags = append(flags, "-run="+namePattern(runNames))
+	} else {
+		// Only benchmarks were selected, so skip everything else
+		flags = append(flags, "-run=^$")
+	}
+	if len(benchNames) > 0 {
+		flags = append(flags, "-bench="+namePattern(benchNames))
+	}
+	return flags
+}
+
+// splitNames returns the unique names of tests that are selected with -run and
+// the unique names of benchmarks that are selected with -bench
+func splitNames(tests []Test) (runNames, benchNames []string) {
+	seen := make(map[string]bool)
+	for _, test := range tests {
+		if seen[test.Name] {
+			continue
+		}
+		seen[test.Name] = true
+		if test.Kind == KindBenchmark {
+			benchNames = append(benchNames, test.Name)
+		} else {
+			runNames = append(runNames, test.Name)
+		}
+	}
+	return runNames, benchNames
+}
+
+// namePattern returns a pattern matching exactly the given top-level names
+func namePattern(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return fmt.Sprintf("^(%s)$", strings.Join(quoted, "|"))
+}
@@ -0,0 +1,229 @@
+package testlist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInvocations(t *testing.T) {
+	tests := []struct {
+		name  string
+		tests []Test
+		opts  InvocationOptions
+		want  []Invocation
+	}{
+		{
+			name: "packages run separately without all tests",
+			tests: []Test{
+				{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+				{Package: "pkg/b", Name: "TestTwo", Kind: KindTest},
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^(TestOne)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(TestTwo)$"}, Packages: []string{"pkg/b"}},
+			},
+		},
+		{
+			name: "exclusive tests run on their own after the others",
+			tests: []Test{
+				{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+				{Package: "pkg/a", Name: "TestPorts", Kind: KindTest},
+				{Package: "pkg/a", Name: "TestTwo", Kind: KindTest},
+			},
+			opts: InvocationOptions{
+				Exclusive: func(test Test) bool { return test.Name == "TestPorts" },
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^(TestOne|TestTwo)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(TestPorts)$"}, Packages: []string{"pkg/a"}},
+			},
+		},
+		{
+			name: "split tests run their subtests and the rest separately",
+			tests: []Test{
+				{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+				{Package: "pkg/a", Name: "TestTable/a", Kind: KindTest},
+				{Package: "pkg/a", Name: "TestTable/b", Kind: KindTest},
+				{Package: "pkg/a", Name: "TestTable", Kind: KindTest, Skip: "^(a|b|c)$"},
+				{Package: "pkg/a", Name: "TestOther/x.y", Kind: KindTest},
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^(TestOne)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(TestTable)$/^(a|b)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(TestTable)$", "-skip=^(TestTable)$/^(a|b|c)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(TestOther)$/^(x\\.y)$"}, Packages: []string{"pkg/a"}},
+			},
+		},
+		{
+			name: "exclusive split tests run after the others",
+			tests: []Test{
+				{Package: "pkg/a", Name: "TestPorts/a", Kind: KindTest},
+				{Package: "pkg/a", Name: "TestPorts", Kind: KindTest, Skip: "^(a)$"},
+				{Package: "pkg/a", Name: "TestTable/a", Kind: KindTest},
+				{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+			},
+			opts: InvocationOptions{
+				Exclusive: func(test Test) bool { return test.topLevel() == "TestPorts" },
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^(TestOne)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(TestTable)$/^(a)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(TestPorts)$/^(a)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(TestPorts)$", "-skip=^(TestPorts)$/^(a)$"}, Packages: []string{"pkg/a"}},
+			},
+		},
+		{
+			name: "packages grouped when names don't leak",
+			tests: []Test{
+				{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+				{Package: "pkg/b", Name: "TestTwo", Kind: KindTest},
+			},
+			opts: InvocationOptions{
+				All: []Test{
+					{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+					{Package: "pkg/a", Name: "TestThree", Kind: KindTest},
+					{Package: "pkg/b", Name: "TestTwo", Kind: KindTest},
+				},
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^(TestOne|TestTwo)$"}, Packages: []string{"pkg/a", "pkg/b"}},
+			},
+		},
+		{
+			name: "packages split when names would leak",
+			tests: []Test{
+				{Package: "pkg/a", Name: "TestFoo", Kind: KindTest},
+				{Package: "pkg/b", Name: "TestBar", Kind: KindTest},
+				{Package: "pkg/c", Name: "TestBaz", Kind: KindTest},
+			},
+			opts: InvocationOptions{
+				All: []Test{
+					{Package: "pkg/a", Name: "TestFoo", Kind: KindTest},
+					{Package: "pkg/b", Name: "TestBar", Kind: KindTest},
+					{Package: "pkg/b", Name: "TestFoo", Kind: KindTest}, // in another chunk
+					{Package: "pkg/c", Name: "TestBaz", Kind: KindTest},
+				},
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^(TestFoo|TestBaz)$"}, Packages: []string{"pkg/a", "pkg/c"}},
+				{Flags: []string{"-run=^(TestBar)$"}, Packages: []string{"pkg/b"}},
+			},
+		},
+		{
+			name: "shared names in the same chunk are grouped",
+			tests: []Test{
+				{Package: "pkg/a", Name: "TestFoo", Kind: KindTest},
+				{Package: "pkg/b", Name: "TestFoo", Kind: KindTest},
+			},
+			opts: InvocationOptions{
+				All: []Test{
+					{Package: "pkg/a", Name: "TestFoo", Kind: KindTest},
+					{Package: "pkg/b", Name: "TestFoo", Kind: KindTest},
+				},
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^(TestFoo)$"}, Packages: []string{"pkg/a", "pkg/b"}},
+			},
+		},
+		{
+			name: "benchmarks only",
+			tests: []Test{
+				{Package: "pkg/a", Name: "BenchmarkOne", Kind: KindBenchmark},
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^$", "-bench=^(BenchmarkOne)$"}, Packages: []string{"pkg/a"}},
+			},
+		},
+		{
+			name: "examples and fuzz seed corpus with benchmarks",
+			tests: []Test{
+				{Package: "pkg/a", Name: "ExampleOne", Kind: KindExample},
+				{Package: "pkg/a", Name: "FuzzOne", Kind: KindFuzz},
+				{Package: "pkg/a", Name: "BenchmarkOne", Kind: KindBenchmark},
+			},
+			want: []Invocation{
+				{Flags: []string{"-run=^(ExampleOne|FuzzOne)$", "-bench=^(BenchmarkOne)$"}, Packages: []string{"pkg/a"}},
+			},
+		},
+		{
+			name: "fuzzing",
+			tests: []Test{
+				{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+				{Package: "pkg/a", Name: "FuzzOne", Kind: KindFuzz},
+				{Package: "pkg/b", Name: "FuzzTwo", Kind: KindFuzz},
+			},
+			opts: InvocationOptions{FuzzTime: "30s"},
+			want: []Invocation{
+				{Flags: []string{"-run=^(TestOne)$"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(FuzzOne)$", "-fuzz=^(FuzzOne)$", "-fuzztime=30s"}, Packages: []string{"pkg/a"}},
+				{Flags: []string{"-run=^(FuzzTwo)$", "-fuzz=^(FuzzTwo)$", "-fuzztime=30s"}, Packages: []string{"pkg/b"}},
+			},
+		},
+		{
+			name:  "no tests",
+			tests: []Test{},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Invocations(tt.tests, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Invocations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvocation_Args(t *testing.T) {
+	inv := Invocation{
+		Flags:    []string{"-run=^(TestOne)$"},
+		Packages: []string{"pkg/a", "pkg/b"},
+	}
+
+	want := []string{"-run=^(TestOne)$", "./pkg/a", "./pkg/b"}
+	if got := inv.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Invocation.Args() = %v, want %v", got, want)
+	}
+}
+
+func TestInvocationsSplitPatterns(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTwo", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestThree", Kind: KindTest},
+		{Package: "pkg/b", Name: "TestOne", Kind: KindTest},
+		{Package: "pkg/b", Name: "TestFour", Kind: KindTest},
+	}
+
+	got := Invocations(tests, InvocationOptions{
+		All:              tests,
+		MaxPatternLength: len("^(TestOne|TestTwo)$"),
+	})
+
+	want := []Invocation{
+		{Flags: []string{"-run=^(TestOne|TestTwo)$"}, Packages: []string{"pkg/a", "pkg/b"}},
+		{Flags: []string{"-run=^(TestThree)$"}, Packages: []string{"pkg/a"}},
+		{Flags: []string{"-run=^(TestFour)$"}, Packages: []string{"pkg/b"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Invocations() = %v, want %v", got, want)
+	}
+
+	for _, inv := range got {
+		for _, flag := range inv.Flags {
+			if len(flag)-len("-run=") > len("^(TestOne|TestTwo)$") {
+				t.Errorf("Invocations() pattern %s is longer than the limit", flag)
+			}
+		}
+	}
+}
+
+func TestNamePattern(t *testing.T) {
+	got := namePattern([]string{"TestPlain", "Test_π", "Test.Dot"})
+	want := `^(TestPlain|Test_π|Test\.Dot)$`
+	if got != want {
+		t.Errorf("namePattern() = %s, want %s", got, want)
+	}
+}
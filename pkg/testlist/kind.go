@@ -0,0 +1,51 @@
+package testlist
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is the type of function that go test runs
+type Kind string
+
+const (
+	KindTest      Kind = "test"      // func TestXxx(*testing.T)
+	KindExample   Kind = "example"   // func ExampleXxx() with an output comment
+	KindBenchmark Kind = "benchmark" // func BenchmarkXxx(*testing.B)
+	KindFuzz      Kind = "fuzz"      // func FuzzXxx(*testing.F)
+)
+
+// Kinds is every kind of test, in the order go test -list reports them
+var Kinds = []Kind{KindTest, KindBenchmark, KindFuzz, KindExample}
+
+// kindPrefixes maps each kind to the function name prefix go test uses
+var kindPrefixes = map[Kind]string{
+	KindTest:      "Test",
+	KindExample:   "Example",
+	KindBenchmark: "Benchmark",
+	KindFuzz:      "Fuzz",
+}
+
+// ParseKinds converts kind names such as "test" or "benchmark" into kinds
+func ParseKinds(names []string) ([]Kind, error) {
+	var kinds []Kind
+	for _, name := range names {
+		kind := Kind(strings.TrimSpace(name))
+		if _, ok := kindPrefixes[kind]; !ok {
+			return nil, fmt.Errorf("unknown test kind: %s", name)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// KindOf returns the kind of test for a function name, or false if the name
+// is not one that go test runs
+func KindOf(name string) (Kind, bool) {
+	for _, kind := range Kinds {
+		if isTest(name, kindPrefixes[kind]) {
+			return kind, true
+		}
+	}
+	return "", false
+}
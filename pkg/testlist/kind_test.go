@@ -0,0 +1,46 @@
+package testlist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   Kind
+		wantOK bool
+	}{
+		{name: "TestSimple", want: KindTest, wantOK: true},
+		{name: "Test", want: KindTest, wantOK: true},
+		{name: "BenchmarkMultiply", want: KindBenchmark, wantOK: true},
+		{name: "FuzzParse", want: KindFuzz, wantOK: true},
+		{name: "ExampleMultiply", want: KindExample, wantOK: true},
+		{name: "Example_suffix", want: KindExample, wantOK: true},
+		{name: "Testable", wantOK: false},
+		{name: "ok  \tgithub.com/lox/gotestchunk\t0.01s", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := KindOf(tt.name)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("KindOf() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseKinds(t *testing.T) {
+	got, err := ParseKinds([]string{"test", "benchmark"})
+	if err != nil {
+		t.Fatalf("ParseKinds() error = %v", err)
+	}
+	if want := []Kind{KindTest, KindBenchmark}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseKinds() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseKinds([]string{"tests"}); err == nil {
+		t.Error("ParseKinds() expected error for unknown kind")
+	}
+}
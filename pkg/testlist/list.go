@@ -2,22 +2,45 @@ package testlist
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // Test represents a discovered test
 type Test struct {
-	Package string
-	Name    string
+	Package string `json:"package"`
+	Name    string `json:"name"` // Top-level name, or TestXxx/sub for a subtest of a test split by subtest
+	Kind    Kind   `json:"kind,omitempty"`
+	File    string `json:"file,omitempty"` // File defining the test, relative to the module root
+
+	// Skip is a pattern of subtests that run separately, set on the rest of
+	// a test that was split by subtest
+	Skip string `json:"skip,omitempty"`
 }
 
 func (t Test) String() string {
 	return t.Package + "." + t.Name
 }
 
+// topLevel returns the name of the top-level test, without any subtest
+func (t Test) topLevel() string {
+	name, _, _ := strings.Cut(t.Name, "/")
+	return name
+}
+
+// split returns whether a test is part of a test split by subtest, either a
+// subtest or the rest of the test
+func (t Test) split() bool {
+	return t.Skip != "" || strings.Contains(t.Name, "/")
+}
+
 // ModuleName returns the name of the module, e.g. github.com/lox/gotestchunk
 func ModuleName() (string, error) {
 	modCmd := exec.Command("go", "list", "-m")
@@ -28,52 +51,96 @@ func ModuleName() (string, error) {
 	return strings.TrimSpace(string(modOutput)), nil
 }
 
+// BuildError is returned when tests can't be listed because a package or its
+// tests don't build
+type BuildError struct {
+	Package string
+	Output  string // Output of go test, including compiler errors
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("failed to list tests for package %s: %s", e.Package, e.Output)
+}
+
+// Discovery is the mechanism used to find tests in a package
+type Discovery string
+
+const (
+	// DiscoveryCompile builds each test binary and runs it with -list
+	DiscoveryCompile Discovery = "compile"
+	// DiscoveryStatic parses _test.go files without compiling anything
+	DiscoveryStatic Discovery = "static"
+)
+
+// Lister discovers tests in packages
+type Lister struct {
+	Workers    int       // Number of packages to list concurrently, defaults to GOMAXPROCS
+	Discovery  Discovery // How to find tests, defaults to DiscoveryCompile
+	BuildFlags []string  // Build flags such as -tags or -race, see BuildFlags
+	Kinds      []Kind    // Kinds of tests to include, defaults to KindTest
+}
+
 // List returns all tests in the given package path
 func List(pkgPath ...string) ([]Test, error) {
+	return (&Lister{}).List(pkgPath...)
+}
+
+// List returns all tests in the given package path. Packages are listed
+// concurrently, but tests are returned in the order that go list reports
+// packages. Failures for individual packages are aggregated into a single error.
+func (l *Lister) List(pkgPath ...string) ([]Test, error) {
 	// Get module name first
 	moduleName, err := ModuleName()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get module name: %w", err)
 	}
 
-	args := []string{"list"}
-	args = append(args, pkgPath...)
+	var listTests func(moduleName string, pkg goPackage) ([]Test, error)
+	switch l.Discovery {
+	case "", DiscoveryCompile:
+		listTests = l.listCompiledTests
+	case DiscoveryStatic:
+		listTests = listStaticTests
+	default:
+		return nil, fmt.Errorf("unknown discovery mode: %s", l.Discovery)
+	}
 
-	// Use go list to get all packages matching the pattern
-	listCmd := exec.Command("go", args...)
-	listCmd.Dir = "."
-	output, err := listCmd.CombinedOutput()
+	pkgs, err := l.listPackages(pkgPath...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list packages: %s", output)
+		return nil, err
 	}
 
-	var allTests []Test
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	workers := l.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
 
-	// For each package, list its tests
-	for scanner.Scan() {
-		pkg := scanner.Text()
-
-		// Get all top-level tests using the full package path
-		cmd := exec.Command("go", "test", "-list", ".", pkg)
-		cmd.Dir = "."
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("failed to list tests for package %s: %s", pkg, output)
-		}
+	// Each worker writes to its own slot so results keep go list order
+	results := make([][]Test, len(pkgs))
+	errs := make([]error, len(pkgs))
 
-		// Get relative package path by removing module prefix
-		relPkg := strings.TrimPrefix(pkg, moduleName+"/")
-
-		testScanner := bufio.NewScanner(strings.NewReader(string(output)))
-		for testScanner.Scan() {
-			testName := testScanner.Text()
-			// Only include Test functions, skip empty lines and other patterns
-			if strings.HasPrefix(testName, "Test") {
-				allTests = append(allTests, Test{
-					Package: relPkg,
-					Name:    testName,
-				})
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i, pkg := range pkgs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, pkg goPackage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = listTests(moduleName, pkg)
+		}(i, pkg)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var allTests []Test
+	for _, tests := range results {
+		for _, test := range tests {
+			if l.includes(test.Kind) {
+				allTests = append(allTests, test)
 			}
 		}
 	}
@@ -81,6 +148,113 @@ func List(pkgPath ...string) ([]Test, error) {
 	return allTests, nil
 }
 
+// includes returns whether tests of the given kind should be listed
+func (l *Lister) includes(kind Kind) bool {
+	if len(l.Kinds) == 0 {
+		return kind == KindTest
+	}
+	for _, k := range l.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// goPackage is the subset of go list -json output used for discovery
+type goPackage struct {
+	ImportPath   string
+	Dir          string
+	TestGoFiles  []string
+	XTestGoFiles []string
+}
+
+// command returns a go command, which reads GOOS, GOARCH and GOFLAGS from
+// the environment as go test does
+func (l *Lister) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = "."
+	return cmd
+}
+
+// listPackages returns the packages matching the given patterns
+func (l *Lister) listPackages(pkgPath ...string) ([]goPackage, error) {
+	args := []string{"list", "-json=ImportPath,Dir,TestGoFiles,XTestGoFiles"}
+	args = append(args, l.BuildFlags...)
+	args = append(args, pkgPath...)
+
+	// Use go list to get all packages matching the pattern, which also
+	// applies build constraints for the given flags and environment
+	listCmd := l.command(args...)
+	var stderr bytes.Buffer
+	listCmd.Stderr = &stderr
+	output, err := listCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %s", stderr.String())
+	}
+
+	var pkgs []goPackage
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for decoder.More() {
+		var pkg goPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, fmt.Errorf("failed to parse go list output: %w", err)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	return pkgs, nil
+}
+
+// listCompiledTests returns the top-level tests in a single package by
+// compiling its test binary and running it with -list
+func (l *Lister) listCompiledTests(moduleName string, pkg goPackage) ([]Test, error) {
+	args := []string{"test"}
+	args = append(args, l.BuildFlags...)
+	args = append(args, "-list", ".", pkg.ImportPath)
+
+	// Get all top-level tests using the full package path
+	cmd := l.command(args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if bytes.Contains(output, []byte("[build failed]")) || bytes.Contains(output, []byte("[setup failed]")) {
+			return nil, &BuildError{Package: pkg.ImportPath, Output: string(output)}
+		}
+		return nil, fmt.Errorf("failed to list tests for package %s: %s", pkg.ImportPath, output)
+	}
+
+	// Get relative package path by removing module prefix
+	relPkg := strings.TrimPrefix(pkg.ImportPath, moduleName+"/")
+
+	// go test -list doesn't report where tests are defined, so find their
+	// files by parsing the package's test files
+	static, err := listStaticTests(moduleName, pkg)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(static))
+	for _, test := range static {
+		files[test.Name] = test.File
+	}
+
+	var tests []Test
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		testName := scanner.Text()
+		// Only include test functions, skip empty lines and other output
+		if kind, ok := KindOf(testName); ok {
+			tests = append(tests, Test{
+				Package: relPkg,
+				Name:    testName,
+				Kind:    kind,
+				File:    files[testName],
+			})
+		}
+	}
+
+	return tests, nil
+}
+
 // Sort sorts a slice of tests by package name and test name
 func Sort(tests []Test) {
 	sort.Slice(tests, func(i, j int) bool {
@@ -165,3 +165,45 @@ func TestPackages(t *testing.T) {
 		})
 	}
 }
+
+func TestListerOrdering(t *testing.T) {
+	moduleRoot, err := GetModuleRoot()
+	if err != nil {
+		t.Fatalf("failed to get module root: %v", err)
+	}
+
+	serial, err := (&Lister{Workers: 1}).List(moduleRoot + "/pkg/example/...")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	concurrent, err := (&Lister{Workers: 8}).List(moduleRoot + "/pkg/example/...")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(serial, concurrent) {
+		t.Errorf("List() concurrent order = %v, want %v", concurrent, serial)
+	}
+}
+
+func TestListerAggregatesErrors(t *testing.T) {
+	moduleRoot, err := GetModuleRoot()
+	if err != nil {
+		t.Fatalf("failed to get module root: %v", err)
+	}
+
+	_, err = (&Lister{Workers: 2}).List(
+		moduleRoot+"/pkg/testlist/testdata/broken/a",
+		moduleRoot+"/pkg/testlist/testdata/broken/b",
+	)
+	if err == nil {
+		t.Fatal("List() expected error for packages that do not compile")
+	}
+
+	for _, pkg := range []string{"testdata/broken/a", "testdata/broken/b"} {
+		if !strings.Contains(err.Error(), pkg) {
+			t.Errorf("List() error missing package %s: %v", pkg, err)
+		}
+	}
+}
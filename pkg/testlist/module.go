@@ -58,3 +58,18 @@ func TestRunWithModuleRoot(t *testing.T, name string, f func(t *testing.T)) {
 		f(t)
 	})
 }
+
+// SetFlakyEnv sets the environment read by the flaky test fixtures in
+// pkg/commands/testdata. Each call gets a new counter directory, so the
+// flaky test fails once again and go test doesn't cache results. With
+// fail, the test that fails every attempt does so.
+func SetFlakyEnv(t *testing.T, fail bool) {
+	t.Helper()
+
+	t.Setenv("FLAKY_DIR", t.TempDir())
+	if fail {
+		t.Setenv("FLAKY_FAIL", "1")
+	} else {
+		t.Setenv("FLAKY_FAIL", "")
+	}
+}
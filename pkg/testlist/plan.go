@@ -0,0 +1,126 @@
+package testlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// PlanVersion is the version of the plan format written by this package.
+// Plans with a different version are rejected rather than misread.
+const PlanVersion = 1
+
+// Plan is a precomputed assignment of tests to every chunk, so that tests
+// are discovered and chunked once rather than independently on each shard
+type Plan struct {
+	Version     int           `json:"version"`
+	Granularity Granularity   `json:"granularity,omitempty"`
+	Strategy    string        `json:"strategy,omitempty"`
+	Estimator   string        `json:"estimator,omitempty"`       // How tests without timing data were estimated
+	Target      time.Duration `json:"target_duration,omitempty"` // Set if the number of chunks was chosen to fit a target duration
+	Weights     []float64     `json:"weights,omitempty"`         // Relative capacity of each chunk, if not equal
+	Rules       *Rules        `json:"rules,omitempty"`           // Rules the plan was made with, which also say which tests run exclusively
+	Chunks      []PlanChunk   `json:"chunks"`
+}
+
+// PlanChunk is the set of tests assigned to a single chunk of a plan
+type PlanChunk struct {
+	Index    int           `json:"index"` // 1-based, matching --chunk
+	Packages []string      `json:"packages"`
+	Tests    []Test        `json:"tests"`
+	Duration time.Duration `json:"duration"` // Estimated from timings
+}
+
+// Plan returns a plan for the given chunks, using the chunker's timings to
+// estimate the duration of each chunk
+func (c *Chunker) Plan(chunks [][]Test) *Plan {
+	balance := c.Balance(chunks)
+
+	plan := &Plan{
+		Version:     PlanVersion,
+		Granularity: c.Granularity,
+		Weights:     c.Capacities,
+		Rules:       c.Rules,
+		Chunks:      make([]PlanChunk, len(chunks)),
+	}
+	for i, tests := range chunks {
+		if tests == nil {
+			tests = []Test{}
+		}
+		plan.Chunks[i] = PlanChunk{
+			Index:    i + 1,
+			Packages: Packages(tests),
+			Tests:    tests,
+			Duration: balance.Durations[i],
+		}
+	}
+	return plan
+}
+
+// Tests returns the tests in every chunk of the plan
+func (p *Plan) Tests() []Test {
+	var tests []Test
+	for _, chunk := range p.Chunks {
+		tests = append(tests, chunk.Tests...)
+	}
+	return tests
+}
+
+// Chunk returns the tests in a chunk of the plan given a 1-based index
+func (p *Plan) Chunk(index int) ([]Test, error) {
+	if index < 1 || index > len(p.Chunks) {
+		return nil, fmt.Errorf("chunk %d out of bounds (total chunks: %d)", index, len(p.Chunks))
+	}
+	return p.Chunks[index-1].Tests, nil
+}
+
+// Write writes the plan as indented JSON
+func (p *Plan) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling plan: %w", err)
+	}
+
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing plan: %w", err)
+	}
+	return nil
+}
+
+// ReadPlan reads a plan written by Plan.Write
+func ReadPlan(r io.Reader) (*Plan, error) {
+	var plan Plan
+	if err := json.NewDecoder(r).Decode(&plan); err != nil {
+		return nil, fmt.Errorf("error parsing plan: %w", err)
+	}
+
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("unsupported plan version %d (want %d)", plan.Version, PlanVersion)
+	}
+	if len(plan.Chunks) == 0 {
+		return nil, fmt.Errorf("plan has no chunks")
+	}
+	if plan.Rules != nil {
+		if err := plan.Rules.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &plan, nil
+}
+
+// LoadPlan reads a plan from a file
+func LoadPlan(filename string) (*Plan, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan: %w", err)
+	}
+	defer f.Close()
+
+	plan, err := ReadPlan(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return plan, nil
+}
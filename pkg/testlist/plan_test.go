@@ -0,0 +1,130 @@
+package testlist
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkerPlan(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "TestA", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestB", Kind: KindTest},
+		{Package: "pkg/b", Name: "TestC", Kind: KindTest},
+	}
+	timings := map[string]time.Duration{
+		"pkg/a.TestA": 3 * time.Second,
+		"pkg/a.TestB": time.Second,
+		"pkg/b.TestC": 2 * time.Second,
+	}
+
+	chunker := &Chunker{Timings: timings}
+	chunks, err := chunker.Chunks(tests, 3)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+
+	plan := chunker.Plan(chunks)
+	if plan.Version != PlanVersion {
+		t.Errorf("Plan.Version = %d, want %d", plan.Version, PlanVersion)
+	}
+	if len(plan.Chunks) != 3 {
+		t.Fatalf("len(Plan.Chunks) = %d, want 3", len(plan.Chunks))
+	}
+
+	var total time.Duration
+	for i, chunk := range plan.Chunks {
+		if chunk.Index != i+1 {
+			t.Errorf("chunk %d has index %d", i, chunk.Index)
+		}
+		if !reflect.DeepEqual(chunk.Packages, Packages(chunk.Tests)) {
+			t.Errorf("chunk %d packages = %v, want %v", i, chunk.Packages, Packages(chunk.Tests))
+		}
+		total += chunk.Duration
+	}
+	if total != 6*time.Second {
+		t.Errorf("total plan duration = %v, want 6s", total)
+	}
+
+	got := plan.Tests()
+	Sort(got)
+	if !reflect.DeepEqual(got, tests) {
+		t.Errorf("Plan.Tests() = %v, want %v", got, tests)
+	}
+
+	// A plan must read back exactly as it was written
+	var buf bytes.Buffer
+	if err := plan.Write(&buf); err != nil {
+		t.Fatalf("Plan.Write() error = %v", err)
+	}
+	read, err := ReadPlan(&buf)
+	if err != nil {
+		t.Fatalf("ReadPlan() error = %v", err)
+	}
+	if !reflect.DeepEqual(read, plan) {
+		t.Errorf("ReadPlan() = %+v, want %+v", read, plan)
+	}
+
+	for i := range chunks {
+		got, err := read.Chunk(i + 1)
+		if err != nil {
+			t.Fatalf("Plan.Chunk(%d) error = %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, plan.Chunks[i].Tests) {
+			t.Errorf("Plan.Chunk(%d) = %v, want %v", i+1, got, plan.Chunks[i].Tests)
+		}
+	}
+	if _, err := read.Chunk(4); err == nil {
+		t.Error("Plan.Chunk(4) expected error")
+	}
+}
+
+func TestReadPlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantError string
+	}{
+		{
+			name:  "valid",
+			input: `{"version": 1, "chunks": [{"index": 1, "packages": ["pkg/a"], "tests": [{"package": "pkg/a", "name": "TestA"}]}]}`,
+		},
+		{
+			name:      "unsupported version",
+			input:     `{"version": 2, "chunks": [{"index": 1}]}`,
+			wantError: "unsupported plan version 2",
+		},
+		{
+			name:      "no chunks",
+			input:     `{"version": 1, "chunks": []}`,
+			wantError: "plan has no chunks",
+		},
+		{
+			name:      "invalid rules",
+			input:     `{"version": 1, "rules": {"rules": [{"regexp": "(", "exclusive": true}]}, "chunks": [{"index": 1}]}`,
+			wantError: "rule 1 has an invalid regexp",
+		},
+		{
+			name:      "invalid json",
+			input:     `{"version":`,
+			wantError: "error parsing plan",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadPlan(strings.NewReader(tt.input))
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("ReadPlan() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("ReadPlan() error = %v, want %q", err, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package testlist
+
+import "time"
+
+// Report describes how tests are spread across chunks, for comparing
+// strategies without running anything
+type Report struct {
+	Strategy  string        `json:"strategy,omitempty"`
+	Estimator string        `json:"estimator,omitempty"`
+	Chunks    []ChunkReport `json:"chunks"`
+	Makespan  time.Duration `json:"makespan"`
+	Imbalance float64       `json:"imbalance"`
+	Untimed   int           `json:"untimed"` // Tests without timing data across all chunks
+}
+
+// ChunkReport describes a single chunk of a Report
+type ChunkReport struct {
+	Index    int           `json:"index"` // 1-based, matching --chunk
+	Tests    int           `json:"tests"`
+	Packages int           `json:"packages"`
+	Duration time.Duration `json:"duration"` // Estimated from timings
+	Untimed  int           `json:"untimed"`  // Tests without timing data, estimated with a default
+}
+
+// Report returns a report of the given chunks, using the chunker's timings
+// to estimate durations
+func (c *Chunker) Report(chunks [][]Test) Report {
+	balance := c.Balance(chunks)
+
+	report := Report{
+		Chunks:    make([]ChunkReport, len(chunks)),
+		Makespan:  balance.Makespan,
+		Imbalance: balance.Imbalance,
+	}
+	for i, tests := range chunks {
+		chunk := ChunkReport{
+			Index:    i + 1,
+			Tests:    len(tests),
+			Packages: len(Packages(tests)),
+			Duration: balance.Durations[i],
+		}
+		for _, test := range tests {
+			if _, ok := c.Timings[test.String()]; !ok {
+				chunk.Untimed++
+			}
+		}
+		report.Untimed += chunk.Untimed
+		report.Chunks[i] = chunk
+	}
+	return report
+}
@@ -0,0 +1,39 @@
+package testlist
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChunkerReport(t *testing.T) {
+	chunks := [][]Test{
+		{
+			{Package: "pkg/a", Name: "TestA"},
+			{Package: "pkg/b", Name: "TestB"},
+		},
+		{
+			{Package: "pkg/c", Name: "TestC"},
+		},
+		{},
+	}
+	chunker := &Chunker{Timings: map[string]time.Duration{
+		"pkg/a.TestA": 2 * time.Second,
+		"pkg/c.TestC": 3 * time.Second,
+	}}
+
+	got := chunker.Report(chunks)
+	want := Report{
+		Chunks: []ChunkReport{
+			{Index: 1, Tests: 2, Packages: 2, Duration: 3 * time.Second, Untimed: 1},
+			{Index: 2, Tests: 1, Packages: 1, Duration: 3 * time.Second},
+			{Index: 3},
+		},
+		Makespan:  3 * time.Second,
+		Imbalance: 0.5,
+		Untimed:   1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Chunker.Report() = %+v, want %+v", got, want)
+	}
+}
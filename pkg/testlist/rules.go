@@ -0,0 +1,448 @@
+package testlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Rules constrain how tests are assigned to chunks. Each rule matches tests
+// and pins them to a chunk, runs them exclusively, or keeps them together
+// with the other tests in a group.
+type Rules struct {
+	Rules []Rule `json:"rules"`
+
+	// Module is the path of the module that test packages are relative to,
+	// so that rules can match full import paths as well
+	Module string `json:"-"`
+}
+
+// Rule matches tests with either a glob or a regular expression, which is
+// matched against both the package path and the full test name. Package
+// paths can be relative to the module or full import paths, e.g. pkg/db and
+// github.com/example/pkg/db.TestMigrate.
+type Rule struct {
+	Match  string `json:"match,omitempty"`  // Glob, where * doesn't match / and ** does
+	Regexp string `json:"regexp,omitempty"` // Regular expression
+
+	// Chunk pins matching tests to a chunk (1-based)
+	Chunk int `json:"chunk,omitempty"`
+
+	// Exclusive tests get a chunk of their own when there are enough
+	// chunks, and otherwise run on their own after the rest of their chunk
+	Exclusive bool `json:"exclusive,omitempty"`
+
+	// Group keeps matching tests in the same chunk as every other test in
+	// a group of the same name
+	Group string `json:"group,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// ReadRules reads and validates rules in JSON format
+func ReadRules(r io.Reader) (*Rules, error) {
+	var rules Rules
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("error parsing rules: %w", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// LoadRules reads rules from a file
+func LoadRules(filename string) (*Rules, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rules: %w", err)
+	}
+	defer f.Close()
+
+	rules, err := ReadRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return rules, nil
+}
+
+// Validate checks that every rule has a valid pattern and does something.
+// Rules built in code rather than read must be validated before use.
+func (r *Rules) Validate() error {
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		switch {
+		case rule.Match != "" && rule.Regexp != "":
+			return fmt.Errorf("rule %d sets both match and regexp", i+1)
+		case rule.Match != "":
+			if !doublestar.ValidatePattern(rule.Match) {
+				return fmt.Errorf("rule %d has an invalid glob: %s", i+1, rule.Match)
+			}
+		case rule.Regexp != "":
+			re, err := regexp.Compile(rule.Regexp)
+			if err != nil {
+				return fmt.Errorf("rule %d has an invalid regexp: %w", i+1, err)
+			}
+			rule.re = re
+		default:
+			return fmt.Errorf("rule %d must set match or regexp", i+1)
+		}
+
+		if rule.Chunk < 0 {
+			return fmt.Errorf("rule %d pins tests to chunk %d, but chunks start at 1", i+1, rule.Chunk)
+		}
+		if rule.Chunk == 0 && !rule.Exclusive && rule.Group == "" {
+			return fmt.Errorf("rule %d must set chunk, exclusive or group", i+1)
+		}
+	}
+	return nil
+}
+
+// matches returns whether a rule matches a test's package or full name,
+// with the package relative to module or as a full import path. The parts of
+// a test split by subtest also match by the top-level test's name.
+func (rule *Rule) matches(module string, test Test) bool {
+	packages := []string{test.Package}
+	if module != "" && test.Package != module && !strings.HasPrefix(test.Package, module+"/") {
+		packages = append(packages, module+"/"+test.Package)
+	}
+	for _, pkg := range packages {
+		for _, name := range []string{pkg, pkg + "." + test.Name, pkg + "." + test.topLevel()} {
+			if rule.re != nil && rule.re.MatchString(name) {
+				return true
+			}
+			if rule.Match != "" {
+				if ok, _ := doublestar.Match(rule.Match, name); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Unmatched returns the rules that match none of tests, which are usually
+// mistakes, such as a misspelled package
+func (r *Rules) Unmatched(tests []Test) []Rule {
+	if r == nil {
+		return nil
+	}
+	var unmatched []Rule
+	for i := range r.Rules {
+		if !slices.ContainsFunc(tests, func(test Test) bool { return r.Rules[i].matches(r.Module, test) }) {
+			unmatched = append(unmatched, r.Rules[i])
+		}
+	}
+	return unmatched
+}
+
+// Exclusive returns whether a test must run on its own
+func (r *Rules) Exclusive(test Test) bool {
+	if r == nil {
+		return false
+	}
+	for i := range r.Rules {
+		if r.Rules[i].Exclusive && r.Rules[i].matches(r.Module, test) {
+			return true
+		}
+	}
+	return false
+}
+
+// pin returns the chunk that a test is pinned to by the first rule that
+// pins it, or 0 if it isn't pinned
+func (r *Rules) pin(test Test) int {
+	for i := range r.Rules {
+		if r.Rules[i].Chunk > 0 && r.Rules[i].matches(r.Module, test) {
+			return r.Rules[i].Chunk
+		}
+	}
+	return 0
+}
+
+// maxChunk returns the highest chunk that any rule pins tests to
+func (r *Rules) maxChunk() int {
+	var chunk int
+	if r != nil {
+		for _, rule := range r.Rules {
+			chunk = max(chunk, rule.Chunk)
+		}
+	}
+	return chunk
+}
+
+// group merges units whose tests belong to the same group into a single
+// unit, keyed by the group's name so that hashing is stable as tests change
+func (r *Rules) group(units []Unit) []Unit {
+	parent := make([]int, len(units))
+	for u := range parent {
+		parent[u] = u
+	}
+	var root func(u int) int
+	root = func(u int) int {
+		if parent[u] != u {
+			parent[u] = root(parent[u])
+		}
+		return parent[u]
+	}
+
+	first := make(map[string]int) // First unit in each group
+	names := make(map[int]string) // Name of a group each unit is in
+	for u, unit := range units {
+		for _, test := range unit.Tests {
+			for i := range r.Rules {
+				rule := &r.Rules[i]
+				if rule.Group == "" || !rule.matches(r.Module, test) {
+					continue
+				}
+				if names[u] == "" || rule.Group < names[u] {
+					names[u] = rule.Group
+				}
+				if v, ok := first[rule.Group]; ok {
+					// Merge into the earlier unit, so merged units keep
+					// the position of their first unit
+					a, b := root(u), root(v)
+					parent[max(a, b)] = min(a, b)
+				} else {
+					first[rule.Group] = u
+				}
+			}
+		}
+	}
+	if len(first) == 0 {
+		return units
+	}
+
+	var merged []Unit
+	var keys []string
+	index := make(map[int]int) // Root unit to merged unit
+	for u, unit := range units {
+		i, ok := index[root(u)]
+		if !ok {
+			i = len(merged)
+			index[root(u)] = i
+			merged = append(merged, Unit{Key: unit.Key})
+			keys = append(keys, "")
+		}
+		merged[i].Tests = append(merged[i].Tests, unit.Tests...)
+		if name := names[u]; name != "" && (keys[i] == "" || name < keys[i]) {
+			keys[i] = name
+		}
+	}
+	for i, key := range keys {
+		if key != "" {
+			merged[i].Key = key
+		}
+	}
+	return merged
+}
+
+// chunks assigns units to chunks with a strategy, giving exclusive units a
+// chunk of their own when there are enough chunks and moving pinned units to
+// their chunk if the strategy didn't put them there
+func (r *Rules) chunks(in Input, strategy Strategy) ([][]Test, error) {
+	pinned := make([]int, len(in.Units))
+	exclusive := make([]bool, len(in.Units))
+	anyPinned := false
+	for u, unit := range in.Units {
+		pinned[u] = -1
+		for _, test := range unit.Tests {
+			exclusive[u] = exclusive[u] || r.Exclusive(test)
+
+			chunk := r.pin(test)
+			if chunk == 0 {
+				continue
+			}
+			if chunk > in.Total {
+				return nil, fmt.Errorf("%s is pinned to chunk %d, but there are only %d chunks", test, chunk, in.Total)
+			}
+			if pinned[u] >= 0 && pinned[u] != chunk-1 {
+				return nil, fmt.Errorf("%s is pinned to chunk %d, but must run in the same chunk as tests pinned to chunk %d", test, chunk, pinned[u]+1)
+			}
+			pinned[u] = chunk - 1
+			anyPinned = true
+		}
+	}
+	if anyPinned {
+		in.Pinned = pinned
+	}
+
+	reserved := reserveChunks(in, pinned, exclusive)
+	if reserved == nil {
+		chunks, err := runStrategy(strategy, in)
+		if err != nil {
+			return nil, err
+		}
+		return enforcePins(in, chunks), nil
+	}
+
+	// Assign the other units to the chunks that aren't reserved
+	var free []int
+	index := make(map[int]int) // Chunk to its index in free
+	for chunk, u := range reserved {
+		if u < 0 {
+			index[chunk] = len(free)
+			free = append(free, chunk)
+		}
+	}
+
+	rest := Input{Timed: in.Timed, Total: len(free), Overheads: in.Overheads}
+	if in.Capacities != nil {
+		for _, chunk := range free {
+			rest.Capacities = append(rest.Capacities, in.Capacities[chunk])
+		}
+	}
+	var restPinned []int
+	for u, unit := range in.Units {
+		if exclusive[u] {
+			continue
+		}
+		rest.Units = append(rest.Units, unit)
+		rest.Weights = append(rest.Weights, in.Weights[u])
+		if pinned[u] >= 0 {
+			restPinned = append(restPinned, index[pinned[u]])
+		} else {
+			restPinned = append(restPinned, -1)
+		}
+	}
+	if anyPinned {
+		rest.Pinned = restPinned
+	}
+
+	chunks := make([][]Test, in.Total)
+	for chunk, u := range reserved {
+		if u >= 0 {
+			chunks[chunk] = in.Units[u].Tests
+		}
+	}
+	if len(rest.Units) > 0 {
+		restChunks, err := runStrategy(strategy, rest)
+		if err != nil {
+			return nil, err
+		}
+		for i, chunk := range enforcePins(rest, restChunks) {
+			chunks[free[i]] = chunk
+		}
+	}
+	return chunks, nil
+}
+
+// reserveChunks returns the exclusive unit reserved for each chunk, or -1
+// for chunks left for other units. Exclusive units take the chunk they are
+// pinned to or else the last chunk not otherwise pinned. It returns nil if
+// there are no exclusive units or not enough chunks to give each its own.
+func reserveChunks(in Input, pinned []int, exclusive []bool) []int {
+	var units []int
+	for u := range in.Units {
+		if exclusive[u] {
+			units = append(units, u)
+		}
+	}
+	if len(units) == 0 {
+		return nil
+	}
+
+	reserved := make([]int, in.Total)
+	taken := make([]bool, in.Total) // Chunks that other units are pinned to
+	for chunk := range reserved {
+		reserved[chunk] = -1
+	}
+	others := false
+	for u := range in.Units {
+		if !exclusive[u] {
+			others = true
+			if pinned[u] >= 0 {
+				taken[pinned[u]] = true
+			}
+		}
+	}
+
+	for _, u := range units {
+		if chunk := pinned[u]; chunk >= 0 {
+			if reserved[chunk] >= 0 || taken[chunk] {
+				return nil
+			}
+			reserved[chunk] = u
+		}
+	}
+
+	next := in.Total - 1
+	for _, u := range units {
+		if pinned[u] >= 0 {
+			continue
+		}
+		for next >= 0 && (reserved[next] >= 0 || taken[next]) {
+			next--
+		}
+		if next < 0 {
+			return nil
+		}
+		reserved[next] = u
+	}
+
+	// The other units need at least one chunk
+	if others {
+		free := 0
+		for _, u := range reserved {
+			if u < 0 {
+				free++
+			}
+		}
+		if free == 0 {
+			return nil
+		}
+	}
+	return reserved
+}
+
+// enforcePins moves the tests of pinned units into their chunks, for
+// strategies that don't take pins into account
+func enforcePins(in Input, chunks [][]Test) [][]Test {
+	if in.Pinned == nil {
+		return chunks
+	}
+
+	pins := make(map[Test]int)
+	for u, chunk := range in.Pinned {
+		if chunk >= 0 {
+			for _, test := range in.Units[u].Tests {
+				pins[test] = chunk
+			}
+		}
+	}
+
+	placed := make(map[Test]bool)
+	for i, chunk := range chunks {
+		kept := make([]Test, 0, len(chunk))
+		for _, test := range chunk {
+			if pin, ok := pins[test]; ok {
+				if pin != i {
+					continue
+				}
+				placed[test] = true
+			}
+			kept = append(kept, test)
+		}
+		chunks[i] = kept
+	}
+
+	// Keep the order of pinned tests consistent however the strategy
+	// placed them
+	var missing []Test
+	for test := range pins {
+		if !placed[test] {
+			missing = append(missing, test)
+		}
+	}
+	Sort(missing)
+	for _, test := range missing {
+		chunks[pins[test]] = append(chunks[pins[test]], test)
+	}
+	return chunks
+}
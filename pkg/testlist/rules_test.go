@@ -0,0 +1,280 @@
+package testlist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantError string
+	}{
+		{
+			name:  "valid",
+			input: `{"rules": [{"match": "pkg/a", "chunk": 1}, {"regexp": "\\.TestPorts$", "exclusive": true}, {"match": "pkg/fixtures/**", "group": "fixtures"}]}`,
+		},
+		{
+			name:      "no pattern",
+			input:     `{"rules": [{"chunk": 1}]}`,
+			wantError: "rule 1 must set match or regexp",
+		},
+		{
+			name:      "both patterns",
+			input:     `{"rules": [{"match": "pkg/a", "regexp": "pkg/a", "chunk": 1}]}`,
+			wantError: "rule 1 sets both match and regexp",
+		},
+		{
+			name:      "invalid glob",
+			input:     `{"rules": [{"match": "pkg/[a", "chunk": 1}]}`,
+			wantError: "rule 1 has an invalid glob",
+		},
+		{
+			name:      "invalid regexp",
+			input:     `{"rules": [{"match": "pkg/a", "chunk": 1}, {"regexp": "(", "exclusive": true}]}`,
+			wantError: "rule 2 has an invalid regexp",
+		},
+		{
+			name:      "no action",
+			input:     `{"rules": [{"match": "pkg/a"}]}`,
+			wantError: "rule 1 must set chunk, exclusive or group",
+		},
+		{
+			name:      "negative chunk",
+			input:     `{"rules": [{"match": "pkg/a", "chunk": -1}]}`,
+			wantError: "chunks start at 1",
+		},
+		{
+			name:      "invalid json",
+			input:     `{"rules":`,
+			wantError: "error parsing rules",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadRules(strings.NewReader(tt.input))
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("ReadRules() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("ReadRules() error = %v, want %q", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestRulesExclusive(t *testing.T) {
+	rules := mustRules(t, `{"rules": [
+		{"match": "example.com/net", "exclusive": true},
+		{"match": "example.com/**/*.TestGlobal*", "exclusive": true},
+		{"regexp": "^example\\.com/db\\.TestMigrate$", "exclusive": true}
+	]}`)
+
+	tests := []struct {
+		test Test
+		want bool
+	}{
+		{Test{Package: "example.com/net", Name: "TestListen"}, true},
+		{Test{Package: "example.com/net/http", Name: "TestListen"}, false},
+		{Test{Package: "example.com/os/env", Name: "TestGlobalEnv"}, true},
+		{Test{Package: "example.com/db", Name: "TestMigrate"}, true},
+		{Test{Package: "example.com/db", Name: "TestMigrateDown"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Exclusive(tt.test); got != tt.want {
+			t.Errorf("Rules.Exclusive(%s) = %v, want %v", tt.test, got, tt.want)
+		}
+	}
+
+	var none *Rules
+	if none.Exclusive(tests[0].test) {
+		t.Error("nil Rules.Exclusive() = true, want false")
+	}
+}
+
+func TestRulesModule(t *testing.T) {
+	rules := mustRules(t, `{"rules": [
+		{"match": "github.com/example/pkg/db", "chunk": 1},
+		{"match": "github.com/example/**/*.TestListen*", "exclusive": true},
+		{"regexp": "^pkg/(api|web)$", "group": "fixtures"}
+	]}`)
+	rules.Module = "github.com/example"
+
+	tests := []struct {
+		test Test
+		want []bool // Whether each rule matches
+	}{
+		{Test{Package: "pkg/db", Name: "TestMigrate"}, []bool{true, false, false}},
+		{Test{Package: "pkg/net", Name: "TestListenTCP"}, []bool{false, true, false}},
+		{Test{Package: "pkg/api", Name: "TestServe"}, []bool{false, false, true}},
+		{Test{Package: "github.com/example/pkg/db", Name: "TestMigrate"}, []bool{true, false, false}},
+		{Test{Package: "github.com/other/pkg/db", Name: "TestMigrate"}, []bool{false, false, false}},
+	}
+
+	for _, tt := range tests {
+		for i, want := range tt.want {
+			if got := rules.Rules[i].matches(rules.Module, tt.test); got != want {
+				t.Errorf("rule %d matches(%s) = %v, want %v", i+1, tt.test, got, want)
+			}
+		}
+	}
+}
+
+func TestRulesUnmatched(t *testing.T) {
+	rules := mustRules(t, `{"rules": [
+		{"match": "github.com/example/pkg/db", "chunk": 1},
+		{"match": "pkg/missing", "exclusive": true},
+		{"regexp": "^pkg/api\\.", "group": "fixtures"}
+	]}`)
+	rules.Module = "github.com/example"
+	tests := []Test{
+		{Package: "pkg/db", Name: "TestMigrate"},
+		{Package: "pkg/api", Name: "TestServe"},
+	}
+
+	got := rules.Unmatched(tests)
+	if len(got) != 1 || got[0].Match != "pkg/missing" {
+		t.Errorf("Rules.Unmatched() = %+v, want the pkg/missing rule", got)
+	}
+
+	var none *Rules
+	if got := none.Unmatched(tests); got != nil {
+		t.Errorf("nil Rules.Unmatched() = %+v, want nil", got)
+	}
+}
+
+func TestChunkerRules(t *testing.T) {
+	var tests []Test
+	timings := make(map[string]time.Duration)
+	for _, pkg := range []string{"pkg/a", "pkg/b", "pkg/c"} {
+		for _, name := range []string{"Test1", "Test2", "Test3", "Test4"} {
+			test := Test{Package: pkg, Name: name}
+			tests = append(tests, test)
+			timings[test.String()] = time.Second
+		}
+	}
+
+	// chunkOf returns the chunk each test is in
+	chunkOf := func(chunks [][]Test) map[string]int {
+		chunkOf := make(map[string]int)
+		for i, chunk := range chunks {
+			for _, test := range chunk {
+				chunkOf[test.String()] = i + 1
+			}
+		}
+		return chunkOf
+	}
+
+	for _, strategy := range []string{"contiguous", "round-robin", "hash", "greedy", "balanced", "package"} {
+		t.Run(strategy, func(t *testing.T) {
+			s, err := LookupStrategy(strategy)
+			if err != nil {
+				t.Fatalf("LookupStrategy() error = %v", err)
+			}
+
+			chunker := &Chunker{
+				Strategy: s,
+				Timings:  timings,
+				Rules: mustRules(t, `{"rules": [
+					{"match": "pkg/b", "chunk": 1},
+					{"match": "pkg/c.Test4", "exclusive": true},
+					{"match": "pkg/a.Test[12]", "group": "fixtures"},
+					{"match": "pkg/c.Test1", "group": "fixtures"}
+				]}`),
+			}
+			chunks, err := chunker.Chunks(tests, 3)
+			if err != nil {
+				t.Fatalf("Chunker.Chunks() error = %v", err)
+			}
+
+			got := chunkOf(chunks)
+			if len(got) != len(tests) {
+				t.Fatalf("assigned %d tests, want %d", len(got), len(tests))
+			}
+			for _, name := range []string{"Test1", "Test2", "Test3", "Test4"} {
+				if chunk := got["pkg/b."+name]; chunk != 1 {
+					t.Errorf("pkg/b.%s in chunk %d, want pinned to 1", name, chunk)
+				}
+			}
+			if chunk := got["pkg/c.Test4"]; chunk != 3 || len(chunks[2]) != 1 {
+				t.Errorf("pkg/c.Test4 in chunk %d with %v, want alone in chunk 3", chunk, chunks[2])
+			}
+			if got["pkg/a.Test1"] != got["pkg/a.Test2"] || got["pkg/a.Test1"] != got["pkg/c.Test1"] {
+				t.Errorf("fixtures group split across chunks %d, %d and %d", got["pkg/a.Test1"], got["pkg/a.Test2"], got["pkg/c.Test1"])
+			}
+		})
+	}
+}
+
+func TestChunkerRulesExclusiveShared(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "Test1"},
+		{Package: "pkg/a", Name: "Test2"},
+		{Package: "pkg/a", Name: "Test3"},
+	}
+
+	// With more exclusive tests than chunks, they share chunks and run on
+	// their own at run time instead
+	chunker := &Chunker{Rules: mustRules(t, `{"rules": [{"match": "pkg/a", "exclusive": true}]}`)}
+	chunks, err := chunker.Chunks(tests, 2)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+	want := [][]Test{{tests[0], tests[1]}, {tests[2]}}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("Chunker.Chunks() = %v, want %v", chunks, want)
+	}
+}
+
+func TestChunkerRulesErrors(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "Test1"},
+		{Package: "pkg/a", Name: "Test2"},
+	}
+
+	for _, tt := range []struct {
+		name      string
+		rules     string
+		wantError string
+	}{
+		{
+			name:      "pinned beyond chunks",
+			rules:     `{"rules": [{"match": "pkg/a.Test1", "chunk": 3}]}`,
+			wantError: "pkg/a.Test1 is pinned to chunk 3, but there are only 2 chunks",
+		},
+		{
+			name: "group pinned to different chunks",
+			rules: `{"rules": [
+				{"match": "pkg/a.Test1", "chunk": 1},
+				{"match": "pkg/a.Test2", "chunk": 2},
+				{"match": "pkg/a", "group": "a"}
+			]}`,
+			wantError: "pkg/a.Test2 is pinned to chunk 2, but must run in the same chunk as tests pinned to chunk 1",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			chunker := &Chunker{Rules: mustRules(t, tt.rules)}
+			_, err := chunker.Chunks(tests, 2)
+			if err == nil || err.Error() != tt.wantError {
+				t.Errorf("Chunker.Chunks() error = %v, want %q", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func mustRules(t *testing.T, input string) *Rules {
+	t.Helper()
+	rules, err := ReadRules(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadRules() error = %v", err)
+	}
+	return rules
+}
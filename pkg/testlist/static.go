@@ -0,0 +1,128 @@
+package testlist
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// listStaticTests returns the top-level tests in a single package by parsing
+// its test files. Build constraints are honoured because go list only reports
+// the test files that match the current build context.
+func listStaticTests(moduleName string, pkg goPackage) ([]Test, error) {
+	// Get relative package path by removing module prefix
+	relPkg := strings.TrimPrefix(pkg.ImportPath, moduleName+"/")
+
+	// Internal test files come before external ones, matching go test -list
+	files := append(append([]string{}, pkg.TestGoFiles...), pkg.XTestGoFiles...)
+
+	// go test -list reports each kind in turn, so collect them separately
+	byKind := make(map[Kind][]Test)
+	var file string
+	add := func(name string, kind Kind) {
+		byKind[kind] = append(byKind[kind], Test{
+			Package: relPkg,
+			Name:    name,
+			Kind:    kind,
+			File:    testFile(moduleName, pkg, file),
+		})
+	}
+
+	fset := token.NewFileSet()
+	for _, file = range files {
+		path := filepath.Join(pkg.Dir, file)
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil {
+				continue
+			}
+			name := fn.Name.Name
+			switch {
+			case isTest(name, "Test") && isTestFunc(fn, "T"):
+				add(name, KindTest)
+			case isTest(name, "Benchmark") && isTestFunc(fn, "B"):
+				add(name, KindBenchmark)
+			case isTest(name, "Fuzz") && isTestFunc(fn, "F"):
+				add(name, KindFuzz)
+			}
+		}
+
+		// Examples without an output comment are compiled but never run
+		examples := doc.Examples(f)
+		sort.Slice(examples, func(i, j int) bool {
+			return examples[i].Order < examples[j].Order
+		})
+		for _, example := range examples {
+			if example.Output != "" || example.EmptyOutput {
+				add("Example"+example.Name, KindExample)
+			}
+		}
+	}
+
+	var tests []Test
+	for _, kind := range Kinds {
+		tests = append(tests, byKind[kind]...)
+	}
+
+	return tests, nil
+}
+
+// testFile returns the path of a package's test file relative to the module
+// root, matching the package paths of tests
+func testFile(moduleName string, pkg goPackage, file string) string {
+	if pkg.ImportPath == moduleName {
+		return file
+	}
+	return path.Join(strings.TrimPrefix(pkg.ImportPath, moduleName+"/"), file)
+}
+
+// isTest reports whether name looks like a test function name for the given
+// prefix, e.g. Test or TestFoo but not Testfoo
+func isTest(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+// isTestFunc reports whether fn has the signature func(*T) or func(*pkg.T)
+// for the given type name. The testing package may be imported under any
+// name, so only the type name is checked, as go test does.
+func isTestFunc(fn *ast.FuncDecl, arg string) bool {
+	if fn.Type.TypeParams != nil ||
+		fn.Type.Results != nil && len(fn.Type.Results.List) > 0 ||
+		fn.Type.Params.List == nil ||
+		len(fn.Type.Params.List) != 1 ||
+		len(fn.Type.Params.List[0].Names) > 1 {
+		return false
+	}
+
+	ptr, ok := fn.Type.Params.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	if name, ok := ptr.X.(*ast.Ident); ok && name.Name == arg {
+		return true
+	}
+	if sel, ok := ptr.X.(*ast.SelectorExpr); ok && sel.Sel.Name == arg {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package testlist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListStatic(t *testing.T) {
+	moduleRoot, err := GetModuleRoot()
+	if err != nil {
+		t.Fatalf("failed to get module root: %v", err)
+	}
+
+	got, err := (&Lister{Discovery: DiscoveryStatic}).List(moduleRoot + "/pkg/testlist/testdata/static")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	want := []Test{
+		{Package: "pkg/testlist/testdata/static", Name: "TestInternal", Kind: KindTest, File: "pkg/testlist/testdata/static/static_test.go"},
+		{Package: "pkg/testlist/testdata/static", Name: "TestExternal", Kind: KindTest, File: "pkg/testlist/testdata/static/external_test.go"},
+		{Package: "pkg/testlist/testdata/static", Name: "Test", Kind: KindTest, File: "pkg/testlist/testdata/static/external_test.go"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+}
+
+func TestListBuildFlags(t *testing.T) {
+	moduleRoot, err := GetModuleRoot()
+	if err != nil {
+		t.Fatalf("failed to get module root: %v", err)
+	}
+
+	for _, discovery := range []Discovery{DiscoveryCompile, DiscoveryStatic} {
+		t.Run(string(discovery), func(t *testing.T) {
+			lister := &Lister{
+				Discovery:  discovery,
+				BuildFlags: []string{"-tags=integration"},
+			}
+
+			got, err := lister.List(moduleRoot + "/pkg/testlist/testdata/static")
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+
+			want := Test{Package: "pkg/testlist/testdata/static", Name: "TestIntegration", Kind: KindTest, File: "pkg/testlist/testdata/static/integration_test.go"}
+			found := false
+			for _, test := range got {
+				if test == want {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("List() missing %v in %v", want, got)
+			}
+		})
+	}
+}
+
+func TestListStaticMatchesCompile(t *testing.T) {
+	moduleRoot, err := GetModuleRoot()
+	if err != nil {
+		t.Fatalf("failed to get module root: %v", err)
+	}
+
+	for _, pkgPath := range []string{
+		moduleRoot + "/pkg/example/...",
+		moduleRoot + "/pkg/testlist/testdata/static",
+	} {
+		compiled, err := (&Lister{Discovery: DiscoveryCompile, Kinds: Kinds}).List(pkgPath)
+		if err != nil {
+			t.Fatalf("List() compile error = %v", err)
+		}
+
+		static, err := (&Lister{Discovery: DiscoveryStatic, Kinds: Kinds}).List(pkgPath)
+		if err != nil {
+			t.Fatalf("List() static error = %v", err)
+		}
+
+		if !reflect.DeepEqual(static, compiled) {
+			t.Errorf("List(%s) static = %v, compile = %v", pkgPath, static, compiled)
+		}
+	}
+}
+
+func TestListKinds(t *testing.T) {
+	moduleRoot, err := GetModuleRoot()
+	if err != nil {
+		t.Fatalf("failed to get module root: %v", err)
+	}
+
+	pkg := "pkg/testlist/testdata/static"
+	file := pkg + "/kinds_test.go"
+	tests := []struct {
+		name  string
+		kinds []Kind
+		want  []Test
+	}{
+		{
+			name:  "benchmarks",
+			kinds: []Kind{KindBenchmark},
+			want:  []Test{{Package: pkg, Name: "BenchmarkNothing", Kind: KindBenchmark, File: file}},
+		},
+		{
+			name:  "fuzz and examples",
+			kinds: []Kind{KindExample, KindFuzz},
+			want: []Test{
+				{Package: pkg, Name: "FuzzNothing", Kind: KindFuzz, File: file},
+				{Package: pkg, Name: "Example", Kind: KindExample, File: file},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, discovery := range []Discovery{DiscoveryCompile, DiscoveryStatic} {
+			t.Run(tt.name+"/"+string(discovery), func(t *testing.T) {
+				lister := &Lister{Discovery: discovery, Kinds: tt.kinds}
+				got, err := lister.List(moduleRoot + "/" + pkg)
+				if err != nil {
+					t.Fatalf("List() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("List() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestListUnknownDiscovery(t *testing.T) {
+	if _, err := (&Lister{Discovery: "magic"}).List("./..."); err == nil {
+		t.Error("List() expected error for unknown discovery mode")
+	}
+}
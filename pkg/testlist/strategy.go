@@ -0,0 +1,354 @@
+package testlist
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Input is the set of units that a Strategy assigns to chunks
+type Input struct {
+	Units     []Unit                   // Groups of tests to assign, in a consistent order
+	Weights   []time.Duration          // Estimated duration of each unit
+	Timed     bool                     // Whether Weights come from timing data
+	Total     int                      // Number of chunks to return
+	Overheads map[string]time.Duration // Cost of each package, paid once per chunk that runs it
+
+	// Capacities are the relative capacity of each chunk, which should
+	// receive a proportional share of the work, or nil if all chunks are equal
+	Capacities []float64
+
+	// Pinned is the chunk that each unit must be assigned to, or -1 if it
+	// can go anywhere, or nil if no units are pinned. The chunker moves
+	// pinned units that a strategy puts elsewhere, but strategies that
+	// place them first can balance the other units around them.
+	Pinned []int
+}
+
+// pinned returns the chunk that a unit is pinned to, or -1 if it isn't
+func (in Input) pinned(u int) int {
+	if in.Pinned == nil {
+		return -1
+	}
+	return in.Pinned[u]
+}
+
+// share returns a chunk's share of the work relative to an equal split, e.g.
+// 2 for a chunk that should run twice the average
+func (in Input) share(chunk int) float64 {
+	if in.Capacities == nil {
+		return 1
+	}
+	var sum float64
+	for _, c := range in.Capacities {
+		sum += c
+	}
+	return in.Capacities[chunk] * float64(in.Total) / sum
+}
+
+// scaled returns a chunk's load relative to its share of the work, so that
+// chunks of different capacities can be compared
+func (in Input) scaled(chunk int, load time.Duration) time.Duration {
+	if in.Capacities == nil {
+		return load
+	}
+	return time.Duration(float64(load) / in.share(chunk))
+}
+
+// overhead returns the package overhead of a unit
+func (in Input) overhead(u int) time.Duration {
+	if in.Overheads == nil || len(in.Units[u].Tests) == 0 {
+		return 0
+	}
+	return in.Overheads[in.Units[u].Tests[0].Package]
+}
+
+// pkg returns the package of a unit, which is the package of its first test
+func (in Input) pkg(u int) string {
+	if len(in.Units[u].Tests) == 0 {
+		return ""
+	}
+	return in.Units[u].Tests[0].Package
+}
+
+// Strategy assigns units of tests to chunks
+type Strategy interface {
+	// Chunks returns exactly in.Total chunks, each holding the tests of the
+	// units assigned to it. Every shard computes its chunk independently, so
+	// the result must be the same every time for the same input.
+	Chunks(in Input) [][]Test
+}
+
+// StrategyFunc adapts a function to the Strategy interface
+type StrategyFunc func(in Input) [][]Test
+
+// Chunks calls f(in)
+func (f StrategyFunc) Chunks(in Input) [][]Test {
+	return f(in)
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]Strategy{
+		"auto":        autoStrategy{},
+		"contiguous":  StrategyFunc(chunkContiguous),
+		"round-robin": StrategyFunc(chunkRoundRobin),
+		"greedy":      StrategyFunc(chunkGreedy),
+		"balanced":    StrategyFunc(chunkBalanced),
+		"hash":        StrategyFunc(chunkByHash),
+		"package":     StrategyFunc(chunkPackages),
+	}
+)
+
+// RegisterStrategy makes a strategy available by name, replacing any existing
+// strategy with the same name
+func RegisterStrategy(name string, strategy Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = strategy
+}
+
+// LookupStrategy returns the strategy registered with the given name. An
+// empty name returns the auto strategy.
+func LookupStrategy(name string) (Strategy, error) {
+	if name == "" {
+		name = "auto"
+	}
+
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	strategy, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy: %s", name)
+	}
+	return strategy, nil
+}
+
+// StrategyNames returns the names of all registered strategies, sorted
+func StrategyNames() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// autoStrategy balances by duration when timing data is available and
+// otherwise splits units contiguously
+type autoStrategy struct{}
+
+func (autoStrategy) Chunks(in Input) [][]Test {
+	if in.Timed {
+		return chunkBalanced(in)
+	}
+	return chunkContiguous(in)
+}
+
+// chunkContiguous splits units into chunks of roughly equal size, keeping
+// neighbouring units together
+func chunkContiguous(in Input) [][]Test {
+	if len(in.Units) == 0 {
+		return make([][]Test, in.Total)
+	}
+	if in.Capacities != nil {
+		return chunkContiguousShares(in)
+	}
+
+	// Calculate chunk sizes
+	chunkSize := int(math.Ceil(float64(len(in.Units)) / float64(in.Total)))
+	result := make([][]Test, 0, in.Total)
+
+	for i := 0; i < len(in.Units); i += chunkSize {
+		end := i + chunkSize
+		if end > len(in.Units) {
+			end = len(in.Units)
+		}
+		var chunk []Test
+		for _, unit := range in.Units[i:end] {
+			chunk = append(chunk, unit.Tests...)
+		}
+		result = append(result, chunk)
+	}
+
+	// If we have fewer chunks than requested, pad with empty slices
+	for len(result) < in.Total {
+		result = append(result, []Test{})
+	}
+
+	return result
+}
+
+// chunkContiguousShares splits units into contiguous chunks with a number
+// of units proportional to each chunk's capacity
+func chunkContiguousShares(in Input) [][]Test {
+	chunks := make([][]Test, in.Total)
+	start := 0
+	var cumulative float64
+	for i := range chunks {
+		cumulative += in.share(i)
+		end := int(math.Round(float64(len(in.Units)) * cumulative / float64(in.Total)))
+		if i == in.Total-1 {
+			end = len(in.Units)
+		}
+		for _, unit := range in.Units[start:max(start, end)] {
+			chunks[i] = append(chunks[i], unit.Tests...)
+		}
+		start = max(start, end)
+	}
+	return chunks
+}
+
+// chunkRoundRobin deals units out to each chunk in turn. With capacities,
+// each unit goes to the chunk furthest below its share of units.
+func chunkRoundRobin(in Input) [][]Test {
+	chunks := make([][]Test, in.Total)
+	counts := make([]int, in.Total)
+	for i, unit := range in.Units {
+		chunk := i % in.Total
+		if in.Capacities != nil {
+			for j := range counts {
+				if float64(counts[j]+1)/in.share(j) < float64(counts[chunk]+1)/in.share(chunk) {
+					chunk = j
+				}
+			}
+		}
+		counts[chunk]++
+		chunks[chunk] = append(chunks[chunk], unit.Tests...)
+	}
+	return chunks
+}
+
+// chunkGreedy assigns units to chunks using the longest processing time
+// algorithm, placing the longest units first into the chunk with the
+// smallest total time
+func chunkGreedy(in Input) [][]Test {
+	order, assigned := assignGreedy(in)
+
+	chunks := make([][]Test, in.Total)
+	for _, u := range order {
+		chunks[assigned[u]] = append(chunks[assigned[u]], in.Units[u].Tests...)
+	}
+	return chunks
+}
+
+// assignGreedy returns the order that units were placed in by the longest
+// processing time algorithm, and the chunk each unit was assigned to
+func assignGreedy(in Input) (order, assigned []int) {
+	// Sort by duration (longest first)
+	order = make([]int, len(in.Units))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return in.Weights[order[a]] > in.Weights[order[b]]
+	})
+
+	// Track the total time of each chunk, and the packages it runs
+	assigned = make([]int, len(in.Units))
+	chunkTimes := make([]time.Duration, in.Total)
+	packages := make([]map[string]bool, in.Total)
+	for i := range packages {
+		packages[i] = make(map[string]bool)
+	}
+
+	// cost returns a chunk's time before the unit's own time is added, which
+	// includes the unit's package overhead unless the chunk already pays it
+	cost := func(chunk, u int) time.Duration {
+		if packages[chunk][in.pkg(u)] {
+			return chunkTimes[chunk]
+		}
+		return chunkTimes[chunk] + in.overhead(u)
+	}
+
+	place := func(u, chunk int) {
+		assigned[u] = chunk
+		chunkTimes[chunk] = cost(chunk, u) + in.Weights[u]
+		packages[chunk][in.pkg(u)] = true
+	}
+
+	// Place pinned units first, so the others are balanced around them
+	for _, u := range order {
+		if chunk := in.pinned(u); chunk >= 0 {
+			place(u, chunk)
+		}
+	}
+
+	// Distribute units using a greedy algorithm
+	for _, u := range order {
+		if in.pinned(u) >= 0 {
+			continue
+		}
+
+		// Find the chunk that would finish soonest relative to its share
+		minIndex := 0
+		minTime := in.scaled(0, cost(0, u)+in.Weights[u])
+		for i := 1; i < in.Total; i++ {
+			if t := in.scaled(i, cost(i, u)+in.Weights[u]); t < minTime {
+				minIndex = i
+				minTime = t
+			}
+		}
+		place(u, minIndex)
+	}
+
+	return order, assigned
+}
+
+// chunkPackages merges units by package and assigns whole packages using the
+// greedy strategy, regardless of the chunker's granularity. Packages that
+// share a unit, such as a group of tests kept together, are merged too.
+func chunkPackages(in Input) [][]Test {
+	parent := make(map[string]string)
+	var root func(pkg string) string
+	root = func(pkg string) string {
+		if parent[pkg] != pkg {
+			parent[pkg] = root(parent[pkg])
+		}
+		return parent[pkg]
+	}
+	for _, unit := range in.Units {
+		for _, test := range unit.Tests {
+			if _, ok := parent[test.Package]; !ok {
+				parent[test.Package] = test.Package
+			}
+			if a, b := root(unit.Tests[0].Package), root(test.Package); a != b {
+				parent[b] = a
+			}
+		}
+	}
+
+	merged := Input{Timed: in.Timed, Total: in.Total, Overheads: in.Overheads, Capacities: in.Capacities}
+	index := make(map[string]int)
+	var pinned []int
+	for i, unit := range in.Units {
+		for _, test := range unit.Tests {
+			key := root(test.Package)
+			j, ok := index[key]
+			if !ok {
+				j = len(merged.Units)
+				index[key] = j
+				merged.Units = append(merged.Units, Unit{Key: key})
+				merged.Weights = append(merged.Weights, 0)
+				pinned = append(pinned, -1)
+			}
+			merged.Units[j].Tests = append(merged.Units[j].Tests, test)
+			// A package goes where its first pinned unit is pinned
+			if pinned[j] < 0 {
+				pinned[j] = in.pinned(i)
+			}
+		}
+		// Attribute the unit's weight to the package of its first test
+		if len(unit.Tests) > 0 {
+			merged.Weights[index[root(unit.Tests[0].Package)]] += in.Weights[i]
+		}
+	}
+	if in.Pinned != nil {
+		merged.Pinned = pinned
+	}
+	return chunkGreedy(merged)
+}
@@ -0,0 +1,192 @@
+package testlist
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStrategies(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "Test1"},
+		{Package: "pkg/a", Name: "Test2"},
+		{Package: "pkg/b", Name: "Test3"},
+		{Package: "pkg/b", Name: "Test4"},
+		{Package: "pkg/c", Name: "Test5"},
+	}
+	timings := map[string]time.Duration{
+		"pkg/a.Test1": 100 * time.Millisecond,
+		"pkg/a.Test2": 100 * time.Millisecond,
+		"pkg/b.Test3": 300 * time.Millisecond,
+		"pkg/b.Test4": 100 * time.Millisecond,
+		"pkg/c.Test5": 250 * time.Millisecond,
+	}
+
+	testCases := []struct {
+		strategy string
+		timings  map[string]time.Duration
+		want     [][]Test
+	}{
+		{
+			strategy: "contiguous",
+			want:     [][]Test{{tests[0], tests[1], tests[2]}, {tests[3], tests[4]}},
+		},
+		{
+			strategy: "round-robin",
+			want:     [][]Test{{tests[0], tests[2], tests[4]}, {tests[1], tests[3]}},
+		},
+		{
+			strategy: "greedy",
+			timings:  timings,
+			want:     [][]Test{{tests[2], tests[1]}, {tests[4], tests[0], tests[3]}},
+		},
+		{
+			strategy: "package",
+			timings:  timings,
+			want:     [][]Test{{tests[2], tests[3]}, {tests[4], tests[0], tests[1]}},
+		},
+		{
+			strategy: "auto",
+			want:     [][]Test{{tests[0], tests[1], tests[2]}, {tests[3], tests[4]}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			strategy, err := LookupStrategy(tc.strategy)
+			if err != nil {
+				t.Fatalf("LookupStrategy() error = %v", err)
+			}
+
+			chunker := &Chunker{Strategy: strategy, Timings: tc.timings}
+			got, err := chunker.Chunks(tests, 2)
+			if err != nil {
+				t.Fatalf("Chunker.Chunks() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Chunker.Chunks() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	// Put every test in the last chunk
+	RegisterStrategy("last", StrategyFunc(func(in Input) [][]Test {
+		chunks := make([][]Test, in.Total)
+		for _, unit := range in.Units {
+			chunks[in.Total-1] = append(chunks[in.Total-1], unit.Tests...)
+		}
+		return chunks
+	}))
+
+	strategy, err := LookupStrategy("last")
+	if err != nil {
+		t.Fatalf("LookupStrategy() error = %v", err)
+	}
+
+	tests := []Test{{Package: "pkg/a", Name: "Test1"}}
+	got, err := (&Chunker{Strategy: strategy}).Chunk(tests, 2, 3)
+	if err != nil {
+		t.Fatalf("Chunker.Chunk() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, tests) {
+		t.Errorf("Chunker.Chunk() = %v, want %v", got, tests)
+	}
+
+	found := false
+	for _, name := range StrategyNames() {
+		if name == "last" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("StrategyNames() = %v, missing last", StrategyNames())
+	}
+}
+
+func TestChunkerStrategyErrors(t *testing.T) {
+	if _, err := LookupStrategy("random"); err == nil {
+		t.Error("LookupStrategy() expected error for unknown strategy")
+	}
+
+	// A strategy must return the requested number of chunks
+	broken := StrategyFunc(func(in Input) [][]Test { return nil })
+	if _, err := (&Chunker{Strategy: broken}).Chunks(generateTests(3), 2); err == nil {
+		t.Error("Chunker.Chunks() expected error for wrong number of chunks")
+	}
+}
+
+func TestChunkerCapacities(t *testing.T) {
+	tests := generateTests(120)
+	timings := make(map[string]time.Duration)
+	for _, test := range tests {
+		timings[test.String()] = time.Second
+	}
+
+	testCases := []struct {
+		strategy  string
+		timings   map[string]time.Duration
+		tolerance int // How many tests a chunk may be from its share
+	}{
+		{strategy: "contiguous"},
+		{strategy: "round-robin"},
+		{strategy: "greedy", timings: timings},
+		{strategy: "balanced", timings: timings},
+		{strategy: "package", timings: timings, tolerance: 9},
+		{strategy: "hash", tolerance: 15},
+		{strategy: "hash", timings: timings, tolerance: 3}, // Within hashTolerance of its share
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			strategy, err := LookupStrategy(tc.strategy)
+			if err != nil {
+				t.Fatalf("LookupStrategy() error = %v", err)
+			}
+
+			chunker := &Chunker{Strategy: strategy, Timings: tc.timings, Capacities: []float64{2, 1, 1}}
+			chunks, err := chunker.Chunks(tests, 3)
+			if err != nil {
+				t.Fatalf("Chunker.Chunks() error = %v", err)
+			}
+
+			count := 0
+			for i, want := range []int{60, 30, 30} {
+				count += len(chunks[i])
+				if diff := len(chunks[i]) - want; diff < -tc.tolerance || diff > tc.tolerance {
+					t.Errorf("chunk %d has %d tests, want %d±%d", i+1, len(chunks[i]), want, tc.tolerance)
+				}
+			}
+			if count != len(tests) {
+				t.Errorf("assigned %d tests, want %d", count, len(tests))
+			}
+		})
+	}
+}
+
+func TestChunkerCapacitiesErrors(t *testing.T) {
+	tests := generateTests(10)
+
+	for _, tc := range []struct {
+		name       string
+		capacities []float64
+	}{
+		{name: "too few", capacities: []float64{1, 1}},
+		{name: "too many", capacities: []float64{1, 1, 1, 1}},
+		{name: "zero", capacities: []float64{1, 0, 1}},
+		{name: "negative", capacities: []float64{1, -1, 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chunker := &Chunker{Capacities: tc.capacities}
+			if _, err := chunker.Chunks(tests, 3); err == nil {
+				t.Error("Chunker.Chunks() expected error")
+			}
+		})
+	}
+
+	chunker := &Chunker{Capacities: []float64{1, 1}}
+	if _, err := chunker.ChunksWithin(tests, time.Second); err == nil {
+		t.Error("Chunker.ChunksWithin() expected error with capacities")
+	}
+}
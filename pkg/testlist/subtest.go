@@ -0,0 +1,67 @@
+package testlist
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// splitSubtests replaces each top-level test estimated to take longer than
+// the chunker's SplitSubtests with its subtests recorded in timing data, so
+// they can be spread across chunks. The rest of the test, including any
+// subtests without timing data, stays as a test that skips the split ones.
+// Tests are only split at test granularity.
+func (c *Chunker) splitSubtests(tests []Test) []Test {
+	if c.SplitSubtests <= 0 || c.Timings == nil || (c.Granularity != "" && c.Granularity != GranularityTest) {
+		return tests
+	}
+
+	var result []Test
+	for _, test := range tests {
+		if (test.Kind != KindTest && test.Kind != "") || test.split() {
+			result = append(result, test)
+			continue
+		}
+
+		// A test whose subtests are parallel can report less time than its
+		// subtests, or none at all, so it takes at least their total
+		names, sum := c.subtests(test)
+		if len(names) < 2 || max(c.testTime(test), sum) <= c.SplitSubtests {
+			result = append(result, test)
+			continue
+		}
+
+		for _, name := range names {
+			subtest := test
+			subtest.Name = test.Name + "/" + name
+			result = append(result, subtest)
+		}
+		rest := test
+		rest.Skip = namePattern(names)
+		result = append(result, rest)
+	}
+	return result
+}
+
+// subtests returns the sorted names of a test's direct subtests that have
+// timing data, and their total duration
+func (c *Chunker) subtests(test Test) ([]string, time.Duration) {
+	prefix := test.String() + "/"
+	var names []string
+	var sum time.Duration
+	for key, d := range c.Timings {
+		if name, ok := strings.CutPrefix(key, prefix); ok && !strings.Contains(name, "/") {
+			names = append(names, name)
+			sum += d
+		}
+	}
+	sort.Strings(names)
+	return names, sum
+}
+
+// restTime returns the duration of the rest of a split test, which is the
+// time of the whole test less the time of its split subtests
+func (c *Chunker) restTime(test Test) time.Duration {
+	_, sum := c.subtests(test)
+	return max(c.Timings[test.String()]-sum, 0)
+}
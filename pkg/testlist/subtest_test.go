@@ -0,0 +1,110 @@
+package testlist
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChunkerSplitSubtests(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTable", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTwo", Kind: KindTest},
+	}
+	timings := map[string]time.Duration{
+		"pkg/a.TestOne":           5 * time.Second,
+		"pkg/a.TestTable":         40 * time.Second,
+		"pkg/a.TestTable/a":       9 * time.Second,
+		"pkg/a.TestTable/b":       9 * time.Second,
+		"pkg/a.TestTable/c":       9 * time.Second,
+		"pkg/a.TestTable/d":       9 * time.Second,
+		"pkg/a.TestTable/d/inner": 9 * time.Second,
+		"pkg/a.TestTwo":           5 * time.Second,
+		"pkg/a.TestTwo/x":         2 * time.Second,
+		"pkg/a.TestTwo/y":         2 * time.Second,
+	}
+
+	unsplit := &Chunker{Strategy: StrategyFunc(chunkBalanced), Timings: timings}
+	chunks, err := unsplit.Chunks(tests, 3)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+	if got := unsplit.Balance(chunks).Makespan; got != 40*time.Second {
+		t.Fatalf("unsplit makespan = %v, want 40s", got)
+	}
+
+	chunker := &Chunker{Strategy: StrategyFunc(chunkBalanced), Timings: timings, SplitSubtests: 10 * time.Second}
+	chunks, err = chunker.Chunks(tests, 3)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+
+	// TestTable is split into its direct subtests and the rest, which takes
+	// the 4s not accounted for by subtests, while TestTwo is too short to split
+	var got []Test
+	for _, chunk := range chunks {
+		got = append(got, chunk...)
+	}
+	Sort(got)
+	want := []Test{
+		{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTable", Kind: KindTest, Skip: "^(a|b|c|d)$"},
+		{Package: "pkg/a", Name: "TestTable/a", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTable/b", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTable/c", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTable/d", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTwo", Kind: KindTest},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Chunker.Chunks() tests = %v, want %v", got, want)
+	}
+
+	if got := chunker.Estimate(got); got != 50*time.Second {
+		t.Errorf("Chunker.Estimate() = %v, want 50s", got)
+	}
+	if got := chunker.Balance(chunks).Makespan; got != 18*time.Second {
+		t.Errorf("split makespan = %v, want 18s", got)
+	}
+}
+
+func TestChunkerSplitSubtestsRules(t *testing.T) {
+	tests := []Test{
+		{Package: "pkg/a", Name: "TestOne", Kind: KindTest},
+		{Package: "pkg/a", Name: "TestTable", Kind: KindTest},
+	}
+	timings := map[string]time.Duration{
+		"pkg/a.TestOne":     time.Second,
+		"pkg/a.TestTable":   20 * time.Second,
+		"pkg/a.TestTable/a": 10 * time.Second,
+		"pkg/a.TestTable/b": 10 * time.Second,
+	}
+
+	// Rules match the parts of a split test by the top-level test's name
+	chunker := &Chunker{
+		Strategy:      StrategyFunc(chunkBalanced),
+		Timings:       timings,
+		SplitSubtests: time.Second,
+		Rules:         mustRules(t, `{"rules": [{"match": "pkg/a.TestTable", "chunk": 2}]}`),
+	}
+	chunks, err := chunker.Chunks(tests, 2)
+	if err != nil {
+		t.Fatalf("Chunker.Chunks() error = %v", err)
+	}
+
+	want := [][]Test{
+		{{Package: "pkg/a", Name: "TestOne", Kind: KindTest}},
+		{
+			{Package: "pkg/a", Name: "TestTable/a", Kind: KindTest},
+			{Package: "pkg/a", Name: "TestTable/b", Kind: KindTest},
+			{Package: "pkg/a", Name: "TestTable", Kind: KindTest, Skip: "^(a|b)$"},
+		},
+	}
+	for i := range want {
+		Sort(chunks[i])
+		Sort(want[i])
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("Chunker.Chunks() = %v, want %v", chunks, want)
+	}
+}
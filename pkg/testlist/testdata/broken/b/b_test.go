@@ -0,0 +1,8 @@
+package b
+
+import "testing"
+
+// TestBroken does not compile, so listing its tests fails
+func TestBroken(t *testing.T) {
+	undefined()
+}
@@ -0,0 +1,16 @@
+package estimate
+
+import "testing"
+
+func TestShort(t *testing.T) {
+	t.Log("short")
+}
+
+func TestSubtests(t *testing.T) {
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, func(t *testing.T) {
+			t.Log(name)
+		})
+	}
+	t.Run("c", func(t *testing.T) {})
+}
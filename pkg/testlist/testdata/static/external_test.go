@@ -0,0 +1,7 @@
+package static_test
+
+import "testing"
+
+func TestExternal(t *testing.T) {}
+
+func Test(t *testing.T) {}
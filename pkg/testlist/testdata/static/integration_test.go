@@ -0,0 +1,7 @@
+//go:build integration
+
+package static
+
+import "testing"
+
+func TestIntegration(t *testing.T) {}
@@ -0,0 +1,24 @@
+package static_test
+
+import (
+	"fmt"
+	"testing"
+)
+
+func BenchmarkNothing(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+	}
+}
+
+func FuzzNothing(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {})
+}
+
+func Example() {
+	fmt.Println("hello")
+	// Output: hello
+}
+
+func Example_noOutput() {
+	fmt.Println("not run")
+}
@@ -0,0 +1,2 @@
+// Package static is used to test static test discovery
+package static
@@ -0,0 +1,18 @@
+package static
+
+import (
+	"os"
+	tt "testing"
+)
+
+func TestMain(m *tt.M) {
+	os.Exit(m.Run())
+}
+
+func TestInternal(t *tt.T) {}
+
+func Testable() bool { return true }
+
+type suite struct{}
+
+func (suite) TestMethod(t *tt.T) {}
@@ -0,0 +1,183 @@
+package testrunner
+
+import (
+	"sort"
+	"strings"
+)
+
+// Outcome is the result of testing a package
+type Outcome string
+
+const (
+	// OutcomePass means every test in the package passed or was skipped
+	OutcomePass Outcome = "pass"
+	// OutcomeTestFail means tests failed, or the test binary exited with an
+	// error without a panic
+	OutcomeTestFail Outcome = "test-fail"
+	// OutcomePanic means a test panicked, stopping the test binary
+	OutcomePanic Outcome = "panic"
+	// OutcomeTimeout means the test binary was stopped by go test -timeout
+	OutcomeTimeout Outcome = "timeout"
+	// OutcomeBuildFail means the package or its tests didn't build
+	OutcomeBuildFail Outcome = "build-fail"
+)
+
+// severity orders outcomes from best to worst
+var severity = map[Outcome]int{
+	OutcomePass:      0,
+	OutcomeTestFail:  1,
+	OutcomePanic:     2,
+	OutcomeTimeout:   3,
+	OutcomeBuildFail: 4,
+}
+
+// Worse returns whether o is a more severe outcome than other
+func (o Outcome) Worse(other Outcome) bool {
+	return severity[o] > severity[other]
+}
+
+// PackageResult is the outcome of testing a package
+type PackageResult struct {
+	Package string
+	Outcome Outcome
+	Tests   []string // Top-level tests that failed, in the order they failed
+	Output  []string // Compiler output explaining a build failure
+
+	attempt int
+}
+
+// packageRun is the state of a package while its tests run
+type packageRun struct {
+	tests    []string
+	build    []string
+	panicked bool
+	timedOut bool
+	setup    bool // Whether go test reported a build or setup failure
+}
+
+// outcomes works out the outcome of each package from test events
+type outcomes struct {
+	results map[string]*PackageResult
+	running map[string]*packageRun
+}
+
+// handle processes a test event from the given attempt
+func (o *outcomes) handle(event TestEvent, attempt int) {
+	if o.running == nil {
+		o.results = make(map[string]*PackageResult)
+		o.running = make(map[string]*packageRun)
+	}
+
+	pkg := event.Package
+	if pkg == "" {
+		// Build events name the package being built, e.g. pkg [pkg.test]
+		pkg, _, _ = strings.Cut(event.ImportPath, " ")
+	}
+	if pkg == "" {
+		return
+	}
+	run := o.running[pkg]
+	if run == nil {
+		run = &packageRun{}
+		o.running[pkg] = run
+	}
+
+	switch event.Action {
+	case "build-output":
+		if line := strings.TrimRight(event.Output, "\n"); line != "" && !strings.HasPrefix(line, "#") {
+			run.build = append(run.build, line)
+		}
+		return
+	case "build-fail":
+		run.setup = true
+		return
+	case "output":
+		switch {
+		case strings.HasPrefix(event.Output, "panic: test timed out"):
+			run.timedOut = true
+			if event.Test != "" {
+				run.tests = appendTest(run.tests, event.Test)
+			}
+		case strings.HasPrefix(event.Output, "SIGQUIT: quit"):
+			// Hung test binaries are sent SIGQUIT, such as by go test
+			// after -timeout, and fail events for their unfinished tests
+			// follow
+			run.timedOut = true
+		case strings.HasPrefix(event.Output, "panic: "):
+			run.panicked = true
+		case event.Test == "" && (strings.Contains(event.Output, "[build failed]") || strings.Contains(event.Output, "[setup failed]")):
+			run.setup = true
+		}
+		return
+	case "fail":
+		if event.Test != "" {
+			if !strings.Contains(event.Test, "/") {
+				run.tests = appendTest(run.tests, event.Test)
+			}
+			return
+		}
+	case "pass", "skip":
+		if event.Test != "" {
+			return
+		}
+	default:
+		return
+	}
+
+	// The package has finished
+	delete(o.running, pkg)
+	result := &PackageResult{Package: pkg, Outcome: OutcomePass, attempt: attempt}
+	if event.Action == "fail" {
+		result.Tests = run.tests
+		switch {
+		case run.setup || event.FailedBuild != "":
+			result.Outcome = OutcomeBuildFail
+			result.Output = run.build
+		case run.timedOut:
+			result.Outcome = OutcomeTimeout
+		case run.panicked:
+			result.Outcome = OutcomePanic
+		default:
+			result.Outcome = OutcomeTestFail
+		}
+	}
+
+	// A package can run in several invocations, such as for exclusive
+	// tests, so the worst outcome of an attempt wins. A later attempt,
+	// which reruns the failed tests, replaces it.
+	prev, ok := o.results[pkg]
+	switch {
+	case !ok || attempt > prev.attempt:
+		o.results[pkg] = result
+	case attempt == prev.attempt:
+		if result.Outcome.Worse(prev.Outcome) {
+			prev.Outcome = result.Outcome
+			prev.Output = result.Output
+		}
+		for _, test := range result.Tests {
+			prev.Tests = appendTest(prev.Tests, test)
+		}
+	}
+}
+
+// sorted returns the result of each package, sorted by package
+func (o *outcomes) sorted() []PackageResult {
+	results := make([]PackageResult, 0, len(o.results))
+	for _, result := range o.results {
+		results = append(results, *result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Package < results[j].Package
+	})
+	return results
+}
+
+// appendTest appends a test to tests unless it is already there
+func appendTest(tests []string, test string) []string {
+	for _, t := range tests {
+		if t == test {
+			return tests
+		}
+	}
+	return append(tests, test)
+}
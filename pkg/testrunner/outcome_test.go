@@ -0,0 +1,139 @@
+package testrunner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutcomes(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []TestEvent
+		want   []PackageResult
+	}{
+		{
+			name: "pass",
+			events: []TestEvent{
+				{Action: "pass", Package: "pkg/a", Test: "TestOne"},
+				{Action: "pass", Package: "pkg/a"},
+				{Action: "skip", Package: "pkg/b"},
+			},
+			want: []PackageResult{
+				{Package: "pkg/a", Outcome: OutcomePass},
+				{Package: "pkg/b", Outcome: OutcomePass},
+			},
+		},
+		{
+			name: "test failure",
+			events: []TestEvent{
+				{Action: "fail", Package: "pkg/a", Test: "TestOne/sub"},
+				{Action: "fail", Package: "pkg/a", Test: "TestOne"},
+				{Action: "fail", Package: "pkg/a", Test: "TestTwo"},
+				{Action: "fail", Package: "pkg/a"},
+			},
+			want: []PackageResult{
+				{Package: "pkg/a", Outcome: OutcomeTestFail, Tests: []string{"TestOne", "TestTwo"}},
+			},
+		},
+		{
+			name: "build failure",
+			events: []TestEvent{
+				{Action: "build-output", ImportPath: "pkg/a [pkg/a.test]", Output: "# pkg/a [pkg/a.test]\n"},
+				{Action: "build-output", ImportPath: "pkg/a [pkg/a.test]", Output: "a_test.go:3:27: declared and not used: x\n"},
+				{Action: "build-fail", ImportPath: "pkg/a [pkg/a.test]"},
+				{Action: "output", Package: "pkg/a", Output: "FAIL\tpkg/a [build failed]\n"},
+				{Action: "fail", Package: "pkg/a", FailedBuild: "pkg/a [pkg/a.test]"},
+			},
+			want: []PackageResult{
+				{Package: "pkg/a", Outcome: OutcomeBuildFail, Output: []string{"a_test.go:3:27: declared and not used: x"}},
+			},
+		},
+		{
+			name: "build failure from older go",
+			events: []TestEvent{
+				{Action: "output", Package: "pkg/a", Output: "FAIL\tpkg/a [setup failed]\n"},
+				{Action: "fail", Package: "pkg/a"},
+			},
+			want: []PackageResult{
+				{Package: "pkg/a", Outcome: OutcomeBuildFail},
+			},
+		},
+		{
+			name: "panic",
+			events: []TestEvent{
+				{Action: "output", Package: "pkg/a", Test: "TestOne", Output: "panic: assignment to entry in nil map\n"},
+				{Action: "fail", Package: "pkg/a", Test: "TestOne"},
+				{Action: "fail", Package: "pkg/a"},
+			},
+			want: []PackageResult{
+				{Package: "pkg/a", Outcome: OutcomePanic, Tests: []string{"TestOne"}},
+			},
+		},
+		{
+			name: "timeout",
+			events: []TestEvent{
+				{Action: "output", Package: "pkg/a", Test: "TestSlow", Output: "panic: test timed out after 1s\n"},
+				{Action: "fail", Package: "pkg/a"},
+			},
+			want: []PackageResult{
+				{Package: "pkg/a", Outcome: OutcomeTimeout, Tests: []string{"TestSlow"}},
+			},
+		},
+		{
+			name: "hung test binary sent SIGQUIT",
+			events: []TestEvent{
+				{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "SIGQUIT: quit\n"},
+				{Action: "fail", Package: "pkg/a", Test: "TestHung"},
+				{Action: "fail", Package: "pkg/a"},
+			},
+			want: []PackageResult{
+				{Package: "pkg/a", Outcome: OutcomeTimeout, Tests: []string{"TestHung"}},
+			},
+		},
+		{
+			name: "worst outcome of an attempt wins",
+			events: []TestEvent{
+				{Action: "output", Package: "pkg/a", Test: "TestOne", Output: "panic: oops\n"},
+				{Action: "fail", Package: "pkg/a", Test: "TestOne"},
+				{Action: "fail", Package: "pkg/a"},
+				{Action: "fail", Package: "pkg/a", Test: "TestTwo"},
+				{Action: "fail", Package: "pkg/a"},
+				{Action: "pass", Package: "pkg/a"},
+			},
+			want: []PackageResult{
+				{Package: "pkg/a", Outcome: OutcomePanic, Tests: []string{"TestOne", "TestTwo"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o outcomes
+			for _, event := range tt.events {
+				o.handle(event, 1)
+			}
+
+			got := o.sorted()
+			for i := range got {
+				got[i].attempt = 0
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outcomes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutcomesRerun(t *testing.T) {
+	var o outcomes
+	o.handle(TestEvent{Action: "fail", Package: "pkg/a", Test: "TestFlaky"}, 1)
+	o.handle(TestEvent{Action: "fail", Package: "pkg/a"}, 1)
+	o.handle(TestEvent{Action: "pass", Package: "pkg/a", Test: "TestFlaky"}, 2)
+	o.handle(TestEvent{Action: "pass", Package: "pkg/a"}, 2)
+
+	// A later attempt replaces the outcome of an earlier one
+	got := o.sorted()
+	if len(got) != 1 || got[0].Outcome != OutcomePass || len(got[0].Tests) != 0 {
+		t.Errorf("outcomes = %+v, want pkg/a to pass", got)
+	}
+}
@@ -0,0 +1,59 @@
+package testrunner
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// outputLines turns lines of go test -json output into events. Lines that
+// aren't JSON, such as go: downloading messages or build errors from older
+// versions of go, are wrapped in output events.
+type outputLines struct {
+	pkg string // Package of the last build output header, e.g. # pkg
+}
+
+// event returns the event for a line of output
+func (l *outputLines) event(line []byte) TestEvent {
+	if trimmed := bytes.TrimSpace(line); bytes.HasPrefix(trimmed, []byte("{")) {
+		var event TestEvent
+		if err := json.Unmarshal(trimmed, &event); err == nil {
+			l.pkg = ""
+			return event
+		}
+	}
+
+	text := strings.TrimRight(string(line), "\r\n")
+	pkg := linePackage(text)
+	switch {
+	case strings.HasPrefix(text, "# "):
+		// Build errors follow a header naming the package
+		l.pkg = pkg
+	case pkg == "":
+		pkg = l.pkg
+	}
+	return TestEvent{Action: "output", Package: pkg, Output: text + "\n"}
+}
+
+// linePackage returns the package that a line of plain go test output is
+// about, such as the result line ok  pkg 0.1s or the build header # pkg, or
+// an empty string if it isn't about a package
+func linePackage(line string) string {
+	if header, ok := strings.CutPrefix(line, "# "); ok {
+		pkg, _, _ := strings.Cut(header, " ")
+		return pkg
+	}
+
+	// Result lines are tab separated, e.g. FAIL\tpkg [build failed]
+	status, rest, ok := strings.Cut(line, "\t")
+	if !ok {
+		return ""
+	}
+	switch strings.TrimSpace(status) {
+	case "ok", "FAIL", "?":
+		pkg, _, _ := strings.Cut(rest, "\t")
+		pkg, _, _ = strings.Cut(pkg, " ")
+		return pkg
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package testrunner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutputLines(t *testing.T) {
+	lines := []string{
+		`{"Action":"run","Package":"pkg/a","Test":"TestOne"}` + "\n",
+		"go: downloading github.com/example/dep v1.0.0\n",
+		"# pkg/b [pkg/b.test]\n",
+		"b_test.go:3:27: declared and not used: x\r\n",
+		"FAIL\tpkg/b [build failed]\n",
+		"ok  \tpkg/c\t0.012s\n",
+		`{"Action":"fail","Package":"pkg/b"}`,
+		"not json {\n",
+	}
+	want := []TestEvent{
+		{Action: "run", Package: "pkg/a", Test: "TestOne"},
+		{Action: "output", Output: "go: downloading github.com/example/dep v1.0.0\n"},
+		{Action: "output", Package: "pkg/b", Output: "# pkg/b [pkg/b.test]\n"},
+		{Action: "output", Package: "pkg/b", Output: "b_test.go:3:27: declared and not used: x\n"},
+		{Action: "output", Package: "pkg/b", Output: "FAIL\tpkg/b [build failed]\n"},
+		{Action: "output", Package: "pkg/c", Output: "ok  \tpkg/c\t0.012s\n"},
+		{Action: "fail", Package: "pkg/b"},
+		{Action: "output", Output: "not json {\n"},
+	}
+
+	var l outputLines
+	var got []TestEvent
+	for _, line := range lines {
+		got = append(got, l.event([]byte(line)))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package testrunner
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing, as there are no process groups to start go
+// test in
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends sig to go test alone, killing it if the signal isn't
+// supported, as on Windows
+func signalGroup(process *os.Process, sig os.Signal) error {
+	if err := process.Signal(sig); err != nil {
+		return process.Kill()
+	}
+	return nil
+}
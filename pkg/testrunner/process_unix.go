@@ -0,0 +1,24 @@
+//go:build unix
+
+package testrunner
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts go test in a process group of its own, which the
+// test binaries it runs join, so they can all be signalled together
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalGroup sends sig to every process in the process group led by process
+func signalGroup(process *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return process.Signal(sig)
+	}
+	return syscall.Kill(-process.Pid, s)
+}
@@ -1,12 +1,17 @@
 package testrunner
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -16,6 +21,16 @@ type EventHandler interface {
 	HandleEvent(event TestEvent) error
 }
 
+// Flusher is implemented by handlers that write what they have collected,
+// such as to a file, so it isn't lost when a run is interrupted
+type Flusher interface {
+	Flush() error
+}
+
+// DefaultGracePeriod is how long go test has to exit after being
+// interrupted before it is killed
+const DefaultGracePeriod = 10 * time.Second
+
 // TestEvent represents a single event from go test -json output
 type TestEvent struct {
 	Action  string  `json:"Action"`
@@ -23,6 +38,17 @@ type TestEvent struct {
 	Test    string  `json:"Test"`
 	Elapsed float64 `json:"Elapsed"`
 	Output  string  `json:"Output"`
+
+	ImportPath  string `json:"ImportPath,omitempty"`  // Package being built, for build-output and build-fail events
+	FailedBuild string `json:"FailedBuild,omitempty"` // Package that failed to build, for a package's fail event
+
+	// Attempt is the attempt an event comes from when failed tests are
+	// rerun, starting at 2 for the first rerun, or zero for the first run
+	Attempt int `json:"Attempt,omitempty"`
+
+	// Flaky is set on the pass event of a top-level test that passes when
+	// rerun after failing
+	Flaky bool `json:"Flaky,omitempty"`
 }
 
 // Runner executes go test and processes the output
@@ -32,6 +58,19 @@ type Runner struct {
 	Handlers []EventHandler  // Handlers for test events
 	Logger   *zerolog.Logger // Optional logger for debug output
 	Stdout   io.Writer       // Writer for JSON output, defaults to os.Stdout
+	Stderr   io.Writer       // Writer for go test's stderr as it runs, defaults to os.Stderr
+
+	// GracePeriod is how long go test has to exit after being interrupted
+	// before it and its test binaries are killed, defaults to
+	// DefaultGracePeriod
+	GracePeriod time.Duration
+
+	// Attempt is set on every event when rerunning failed tests, which
+	// are only run again because they failed, so any top-level test that
+	// passes is marked as flaky. Zero for the first run.
+	Attempt int
+
+	outcomes outcomes
 }
 
 // AddHandler adds an event handler to the runner
@@ -39,8 +78,42 @@ func (r *Runner) AddHandler(handler EventHandler) {
 	r.Handlers = append(r.Handlers, handler)
 }
 
+// Results returns the outcome of every package tested by the runner so far,
+// sorted by package. A package that is tested again when rerunning failed
+// tests takes the outcome of its latest attempt.
+func (r *Runner) Results() []PackageResult {
+	return r.outcomes.sorted()
+}
+
+// Flush flushes every handler that implements Flusher, even after a failed
+// or interrupted run
+func (r *Runner) Flush() error {
+	var errs []error
+	for _, handler := range r.Handlers {
+		if flusher, ok := handler.(Flusher); ok {
+			if err := flusher.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Run executes go test with the given arguments and processes events
 func (r *Runner) Run() error {
+	return r.RunContext(context.Background())
+}
+
+// RunContext is like Run, but interrupts go test when ctx is done. The
+// signal in a SignalError cause, or os.Interrupt otherwise, is forwarded to
+// go test and the test binaries it runs, which are killed if they haven't
+// exited after the grace period. Events written before go test exits are
+// still processed.
+func (r *Runner) RunContext(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("go test interrupted: %w", context.Cause(ctx))
+	}
+
 	if r.Dir != "" {
 		if err := os.Chdir(r.Dir); err != nil {
 			return fmt.Errorf("error changing directory: %w", err)
@@ -62,6 +135,7 @@ func (r *Runner) Run() error {
 
 	// Set up command
 	cmd := exec.Command("go", args...)
+	setProcessGroup(cmd)
 
 	// Create pipe for stdout
 	stdout, err := cmd.StdoutPipe()
@@ -69,14 +143,19 @@ func (r *Runner) Run() error {
 		return fmt.Errorf("error creating stdout pipe: %w", err)
 	}
 
-	// Capture stderr to buffer
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	// Stream stderr as it is written, rather than after go test exits
+	cmd.Stderr = r.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("error starting command: %w", err)
 	}
+	exited := make(chan struct{})
+	defer close(exited)
+	go r.interrupt(ctx, cmd.Process, exited)
 
 	// Default Stdout to os.Stdout if not set
 	if r.Stdout == nil {
@@ -87,47 +166,118 @@ func (r *Runner) Run() error {
 	done := make(chan error, 1)
 	go func() {
 		defer close(done)
-		decoder := json.NewDecoder(stdout)
+		// Drain anything left unread so the command can exit
+		defer func() { _, _ = io.Copy(io.Discard, stdout) }()
+		reader := bufio.NewReader(stdout)
 		encoder := json.NewEncoder(r.Stdout)
+		var lines outputLines
+		var tests unfinished
 
-		for decoder.More() {
-			var event TestEvent
-			if err := decoder.Decode(&event); err != nil {
-				done <- fmt.Errorf("error decoding test output: %w", err)
-				return
+		emit := func(event TestEvent) error {
+			if r.Attempt > 0 {
+				event.Attempt = r.Attempt
+				event.Flaky = event.Action == "pass" && event.Test != "" && !strings.Contains(event.Test, "/")
 			}
 
+			r.outcomes.handle(event, max(r.Attempt, 1))
+
 			// Process event through all handlers
 			for _, handler := range r.Handlers {
 				if err := handler.HandleEvent(event); err != nil {
-					done <- fmt.Errorf("error handling event: %w", err)
-					return
+					return fmt.Errorf("error handling event: %w", err)
 				}
 			}
 
 			// Write the event to Stdout
 			if err := encoder.Encode(event); err != nil {
-				done <- fmt.Errorf("error encoding event: %w", err)
+				return fmt.Errorf("error encoding event: %w", err)
+			}
+			return nil
+		}
+
+		for {
+			line, readErr := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				for _, event := range tests.events(lines.event(line)) {
+					if err := emit(event); err != nil {
+						done <- err
+						return
+					}
+				}
+			}
+
+			if readErr != nil {
+				if readErr != io.EOF {
+					done <- fmt.Errorf("error reading test output: %w", readErr)
+					return
+				}
+				break
+			}
+		}
+
+		// Tests can be left unfinished if go test was killed
+		for _, event := range tests.flush() {
+			if err := emit(event); err != nil {
+				done <- err
 				return
 			}
 		}
 		done <- nil
 	}()
 
+	// Wait for event processing to finish first, as Wait closes the pipe
+	processErr := <-done
+
 	// Wait for command to finish
-	if err := cmd.Wait(); err != nil {
-		stderrOutput := stderr.String()
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return errors.Join(fmt.Errorf("go test interrupted: %w", context.Cause(ctx)), processErr)
+	}
+	if err != nil {
 		r.Logger.Error().
-			Str("stderr", stderrOutput).
 			Int("exit_code", cmd.ProcessState.ExitCode()).
 			Msg("Test command failed")
 		return fmt.Errorf("test command failed: %w", err)
 	}
 
-	// Wait for event processing to finish
-	if err := <-done; err != nil {
-		return err
+	return processErr
+}
+
+// interrupt forwards the signal that cancelled ctx to the go test process
+// group, then kills it if it hasn't exited by the end of the grace period
+func (r *Runner) interrupt(ctx context.Context, process *os.Process, exited <-chan struct{}) {
+	select {
+	case <-ctx.Done():
+	case <-exited:
+		return
 	}
 
-	return nil
+	sig := os.Interrupt
+	var sigErr *SignalError
+	if errors.As(context.Cause(ctx), &sigErr) {
+		sig = sigErr.Signal
+	}
+	grace := r.GracePeriod
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+
+	r.Logger.Warn().
+		Str("signal", sig.String()).
+		Dur("grace_period", grace).
+		Msg("Interrupting go test")
+	if err := signalGroup(process, sig); err != nil {
+		r.Logger.Debug().Err(err).Msg("Error signalling go test")
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		r.Logger.Warn().Msg("Killing go test, as it didn't exit within the grace period")
+		if err := signalGroup(process, os.Kill); err != nil {
+			r.Logger.Debug().Err(err).Msg("Error killing go test")
+		}
+	case <-exited:
+	}
 }
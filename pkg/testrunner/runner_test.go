@@ -2,10 +2,14 @@ package testrunner
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/lox/gotestchunk/pkg/testlist"
 	"github.com/rs/zerolog"
@@ -156,6 +160,161 @@ func TestRunnerInvalidArgs(t *testing.T) {
 	}
 }
 
+// failingHandler fails on the first event it handles
+type failingHandler struct{}
+
+func (failingHandler) HandleEvent(event TestEvent) error {
+	return errors.New("handler failed")
+}
+
+// TestRunnerHandlerError tests that the runner keeps draining go test's
+// output after a handler fails, so go test can exit rather than block on a
+// full pipe
+func TestRunnerHandlerError(t *testing.T) {
+	testlist.TestRunWithModuleRoot(t, "handler error", func(t *testing.T) {
+		logger := zerolog.New(zerolog.NewTestWriter(t))
+		runner := &Runner{
+			Args:   []string{"-count=1", "./pkg/testrunner/testdata/chatty"},
+			Logger: &logger,
+			Stdout: io.Discard,
+		}
+		runner.AddHandler(failingHandler{})
+
+		done := make(chan error, 1)
+		go func() {
+			done <- runner.Run()
+		}()
+
+		select {
+		case err := <-done:
+			if err == nil || !strings.Contains(err.Error(), "handler failed") {
+				t.Errorf("Runner.Run() error = %v, want handler error", err)
+			}
+		case <-time.After(time.Minute):
+			t.Fatal("Runner.Run() didn't return after a handler failed")
+		}
+	})
+}
+
+// TestRunnerStderr tests that stderr from go test is written to Stderr
+func TestRunnerStderr(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t)).
+		Level(zerolog.DebugLevel)
+
+	var stderr bytes.Buffer
+	runner := &Runner{
+		Args:   []string{"-vet=bogus", "./..."},
+		Logger: &logger,
+		Stdout: io.Discard,
+		Stderr: &stderr,
+	}
+
+	if err := runner.Run(); err == nil {
+		t.Error("Runner.Run() expected error for invalid flag")
+	}
+	if !strings.Contains(stderr.String(), "bogus") {
+		t.Errorf("stderr = %q, want it to mention the invalid flag", stderr.String())
+	}
+}
+
+// interruptHandler cancels a context once the sleep test is running, and
+// records whether it was flushed
+type interruptHandler struct {
+	cancel  context.CancelFunc
+	events  []TestEvent
+	flushed bool
+}
+
+func (h *interruptHandler) HandleEvent(event TestEvent) error {
+	h.events = append(h.events, event)
+	if strings.Contains(event.Output, "sleeping") {
+		h.cancel()
+	}
+	return nil
+}
+
+func (h *interruptHandler) Flush() error {
+	h.flushed = true
+	return nil
+}
+
+func TestRunnerInterrupt(t *testing.T) {
+	moduleRoot, err := testlist.GetModuleRoot()
+	if err != nil {
+		t.Fatalf("testlist.GetModuleRoot() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		sleep string
+	}{
+		{
+			name:  "test binary exits when interrupted",
+			sleep: "1",
+		},
+		{
+			name:  "test binary is killed after grace period",
+			sleep: "ignore-interrupt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SLEEP_TEST", tt.sleep)
+			logger := zerolog.New(zerolog.NewTestWriter(t)).
+				Level(zerolog.DebugLevel)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			handler := &interruptHandler{cancel: cancel}
+			runner := &Runner{
+				Args:        []string{"-count=1", filepath.Join(moduleRoot, "pkg/testrunner/testdata/sleep")},
+				Logger:      &logger,
+				Stdout:      io.Discard,
+				GracePeriod: time.Second,
+			}
+			runner.AddHandler(handler)
+
+			start := time.Now()
+			err := runner.RunContext(ctx)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Runner.RunContext() error = %v, want context.Canceled", err)
+			}
+			if elapsed := time.Since(start); elapsed > 30*time.Second {
+				t.Errorf("Runner.RunContext() took %v, want go test to stop when interrupted", elapsed)
+			}
+			if len(handler.events) == 0 {
+				t.Error("expected events before the interrupt, got none")
+			}
+
+			if err := runner.Flush(); err != nil {
+				t.Fatalf("Runner.Flush() error = %v", err)
+			}
+			if !handler.flushed {
+				t.Error("Runner.Flush() didn't flush the handler")
+			}
+		})
+	}
+}
+
+func TestRunnerCancelledContext(t *testing.T) {
+	logger := zerolog.New(zerolog.NewTestWriter(t)).
+		Level(zerolog.DebugLevel)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(&SignalError{Signal: os.Interrupt})
+
+	runner := &Runner{
+		Args:   []string{"./..."},
+		Logger: &logger,
+	}
+	err := runner.RunContext(ctx)
+	var sigErr *SignalError
+	if !errors.As(err, &sigErr) || sigErr.Signal != os.Interrupt {
+		t.Errorf("Runner.RunContext() error = %v, want SignalError for interrupt", err)
+	}
+}
+
 func TestRunnerWithJSON(t *testing.T) {
 	tests := []struct {
 		name      string
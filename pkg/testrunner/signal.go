@@ -0,0 +1,37 @@
+package testrunner
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// SignalError is the cause of a context cancelled by NotifyContext
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return "received " + e.Signal.String()
+}
+
+// NotifyContext returns a copy of parent that is cancelled when one of the
+// signals arrives, with a SignalError as its cause so that RunContext
+// forwards the same signal to go test. The signals are caught until stop is
+// called.
+func NotifyContext(parent context.Context, signals ...os.Signal) (ctx context.Context, stop context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	go func() {
+		select {
+		case sig := <-ch:
+			cancel(&SignalError{Signal: sig})
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(ch)
+		cancel(context.Canceled)
+	}
+}
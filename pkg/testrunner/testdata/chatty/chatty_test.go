@@ -0,0 +1,13 @@
+package chatty
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestChatty writes more output than fits in a pipe's buffer
+func TestChatty(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		fmt.Printf("line %d of output that go test has to write before it can exit\n", i)
+	}
+}
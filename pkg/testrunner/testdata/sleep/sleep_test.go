@@ -0,0 +1,32 @@
+package sleep
+
+import (
+	"os"
+	"os/signal"
+	"testing"
+	"time"
+)
+
+// TestQuick passes before TestSleep starts, taking long enough to be timed
+func TestQuick(t *testing.T) {
+	time.Sleep(20 * time.Millisecond)
+}
+
+// TestSleep sleeps until it is interrupted, or killed if it ignores
+// interrupts. It creates the SLEEP_STARTED file, if set, once sleeping.
+func TestSleep(t *testing.T) {
+	if os.Getenv("SLEEP_TEST") == "" {
+		t.Skip("SLEEP_TEST not set")
+	}
+	if os.Getenv("SLEEP_TEST") == "ignore-interrupt" {
+		signal.Ignore(os.Interrupt)
+	}
+
+	t.Log("sleeping")
+	if started := os.Getenv("SLEEP_STARTED"); started != "" {
+		if err := os.WriteFile(started, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Minute)
+}
@@ -0,0 +1,128 @@
+package testrunner
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// unfinishedNote is written as output of a test that never finished
+const unfinishedNote = "    test didn't finish before the test binary exited\n"
+
+// unfinished tracks the tests that have started in each package but not
+// finished, so they can be failed when the test binary exits first, such as
+// when it is sent SIGQUIT, as go test only fails the package. It keeps the
+// goroutine dump a test binary prints on SIGQUIT to show where each of them
+// was stuck.
+type unfinished struct {
+	running map[string][]string // Tests started in each package, in the order they started
+	dumps   map[string][]string // Goroutine dump printed by each package's test binary
+}
+
+// events returns the events to process for event, which are fail events
+// for the package's unfinished tests before the event that fails it
+func (u *unfinished) events(event TestEvent) []TestEvent {
+	if u.running == nil {
+		u.running = make(map[string][]string)
+		u.dumps = make(map[string][]string)
+	}
+
+	pkg := event.Package
+	switch event.Action {
+	case "run":
+		u.running[pkg] = append(u.running[pkg], event.Test)
+	case "output":
+		if _, ok := u.dumps[pkg]; ok || strings.HasPrefix(event.Output, "SIGQUIT: quit") {
+			u.dumps[pkg] = append(u.dumps[pkg], strings.TrimRight(event.Output, "\n"))
+		}
+		// Benchmarks finish with a result line rather than an event
+		if strings.HasPrefix(event.Test, "Benchmark") && strings.Contains(event.Output, " ns/op") {
+			u.finish(pkg, event.Test)
+		}
+	case "pass", "fail", "skip":
+		if event.Test != "" {
+			u.finish(pkg, event.Test)
+			break
+		}
+		if event.Action == "fail" {
+			return append(u.fail(pkg), event)
+		}
+		delete(u.running, pkg)
+		delete(u.dumps, pkg)
+	}
+	return []TestEvent{event}
+}
+
+// flush returns fail events for the unfinished tests of packages that never
+// finished, such as when go test is killed, each followed by a fail event
+// for the package
+func (u *unfinished) flush() []TestEvent {
+	packages := make([]string, 0, len(u.running))
+	for pkg, tests := range u.running {
+		if len(tests) > 0 {
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Strings(packages)
+
+	var events []TestEvent
+	for _, pkg := range packages {
+		events = append(events, u.fail(pkg)...)
+		events = append(events, TestEvent{Action: "fail", Package: pkg})
+	}
+	return events
+}
+
+// finish marks a test as finished, along with its parents for a benchmark,
+// as parent benchmarks don't report results of their own
+func (u *unfinished) finish(pkg, test string) {
+	u.running[pkg] = slices.DeleteFunc(u.running[pkg], func(t string) bool {
+		return t == test || strings.HasPrefix(test, "Benchmark") && strings.HasPrefix(test, t+"/")
+	})
+}
+
+// fail returns output and fail events for each unfinished test in pkg,
+// latest first so subtests fail before their parents. The goroutines that
+// were running a top-level test, from the goroutine dump, are written as
+// its output.
+func (u *unfinished) fail(pkg string) []TestEvent {
+	tests, dump := u.running[pkg], u.dumps[pkg]
+	delete(u.running, pkg)
+	delete(u.dumps, pkg)
+
+	var events []TestEvent
+	for i := len(tests) - 1; i >= 0; i-- {
+		test := tests[i]
+		events = append(events, TestEvent{Action: "output", Package: pkg, Test: test, Output: unfinishedNote})
+		if !strings.Contains(test, "/") {
+			for _, line := range stuckGoroutines(dump, test) {
+				events = append(events, TestEvent{Action: "output", Package: pkg, Test: test, Output: line + "\n"})
+			}
+		}
+		events = append(events, TestEvent{Action: "fail", Package: pkg, Test: test})
+	}
+	return events
+}
+
+// stuckGoroutines returns the goroutines in a goroutine dump whose stacks
+// include the function of a top-level test, or closures within it, separated
+// by blank lines
+func stuckGoroutines(dump []string, test string) []string {
+	var lines, goroutine []string
+	running := false
+	for _, line := range append(dump, "") {
+		if line != "" {
+			goroutine = append(goroutine, line)
+			running = running || strings.Contains(line, "."+test+"(") || strings.Contains(line, "."+test+".func")
+			continue
+		}
+		if running && strings.HasPrefix(goroutine[0], "goroutine ") {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, goroutine...)
+		}
+		goroutine, running = nil, false
+	}
+	return lines
+}
@@ -0,0 +1,120 @@
+package testrunner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnfinished(t *testing.T) {
+	dump := []TestEvent{
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "SIGQUIT: quit\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "goroutine 1 [chan receive]:\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "testing.(*T).Run(0x1)\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "goroutine 7 [sleep]:\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "pkg/a.TestHung.func1(0x2)\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "\ta_test.go:12 +0x1\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "goroutine 8 [select]:\n"},
+		{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "pkg/a.TestHungToo(0x3)\n"},
+	}
+
+	tests := []struct {
+		name   string
+		events []TestEvent
+		want   []TestEvent // Events after the last of events
+	}{
+		{
+			name: "finished tests",
+			events: []TestEvent{
+				{Action: "run", Package: "pkg/a", Test: "TestOne"},
+				{Action: "pass", Package: "pkg/a", Test: "TestOne"},
+				{Action: "fail", Package: "pkg/a"},
+			},
+			want: []TestEvent{
+				{Action: "fail", Package: "pkg/a"},
+			},
+		},
+		{
+			name: "unfinished tests fail before their package",
+			events: append([]TestEvent{
+				{Action: "run", Package: "pkg/a", Test: "TestOne"},
+				{Action: "pass", Package: "pkg/a", Test: "TestOne"},
+				{Action: "run", Package: "pkg/a", Test: "TestHung"},
+				{Action: "run", Package: "pkg/a", Test: "TestHung/sub"},
+			}, append(dump,
+				TestEvent{Action: "fail", Package: "pkg/a"},
+			)...),
+			want: []TestEvent{
+				{Action: "output", Package: "pkg/a", Test: "TestHung/sub", Output: unfinishedNote},
+				{Action: "fail", Package: "pkg/a", Test: "TestHung/sub"},
+				{Action: "output", Package: "pkg/a", Test: "TestHung", Output: unfinishedNote},
+				{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "goroutine 7 [sleep]:\n"},
+				{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "pkg/a.TestHung.func1(0x2)\n"},
+				{Action: "output", Package: "pkg/a", Test: "TestHung", Output: "\ta_test.go:12 +0x1\n"},
+				{Action: "fail", Package: "pkg/a", Test: "TestHung"},
+				{Action: "fail", Package: "pkg/a"},
+			},
+		},
+		{
+			name: "benchmarks finish with results",
+			events: []TestEvent{
+				{Action: "run", Package: "pkg/a", Test: "BenchmarkOne"},
+				{Action: "run", Package: "pkg/a", Test: "BenchmarkOne/sub"},
+				{Action: "output", Package: "pkg/a", Test: "BenchmarkOne/sub", Output: "       1\t       759.0 ns/op\n"},
+				{Action: "fail", Package: "pkg/a"},
+			},
+			want: []TestEvent{
+				{Action: "fail", Package: "pkg/a"},
+			},
+		},
+		{
+			name: "unfinished tests of a passing package",
+			events: []TestEvent{
+				{Action: "run", Package: "pkg/a", Test: "BenchmarkOne"},
+				{Action: "pass", Package: "pkg/a"},
+			},
+			want: []TestEvent{
+				{Action: "pass", Package: "pkg/a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var u unfinished
+			var got []TestEvent
+			for _, event := range tt.events {
+				got = u.events(event)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unfinished.events() = %+v, want %+v", got, tt.want)
+			}
+			if flushed := u.flush(); len(flushed) > 0 {
+				t.Errorf("unfinished.flush() = %+v, want none", flushed)
+			}
+		})
+	}
+}
+
+func TestUnfinishedFlush(t *testing.T) {
+	var u unfinished
+	u.events(TestEvent{Action: "run", Package: "pkg/b", Test: "TestTwo"})
+	u.events(TestEvent{Action: "run", Package: "pkg/a", Test: "TestOne"})
+	u.events(TestEvent{Action: "run", Package: "pkg/c", Test: "TestThree"})
+	u.events(TestEvent{Action: "pass", Package: "pkg/c", Test: "TestThree"})
+
+	// Packages that never finished fail, as when go test is killed
+	want := []TestEvent{
+		{Action: "output", Package: "pkg/a", Test: "TestOne", Output: unfinishedNote},
+		{Action: "fail", Package: "pkg/a", Test: "TestOne"},
+		{Action: "fail", Package: "pkg/a"},
+		{Action: "output", Package: "pkg/b", Test: "TestTwo", Output: unfinishedNote},
+		{Action: "fail", Package: "pkg/b", Test: "TestTwo"},
+		{Action: "fail", Package: "pkg/b"},
+	}
+	if got := u.flush(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unfinished.flush() = %+v, want %+v", got, want)
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/lox/gotestchunk/pkg/testrunner"
 )
 
-// Test represents timing information for a single test
+// Test represents timing information for a single test. An entry with an
+// empty Test is the overhead of running the package, such as building the
+// test binary and TestMain, beyond the time spent in its tests.
 type Test struct {
 	Package string        `json:"package"`
 	Test    string        `json:"test"`
@@ -20,12 +22,15 @@ type Test struct {
 // Collector collects test timing information
 type Collector struct {
 	Tests []Test
+
+	testTime map[string]time.Duration // Sum of top-level test times by package
 }
 
 // HandleEvent processes a test event
 func (c *Collector) HandleEvent(event testrunner.TestEvent) error {
-	// Only process "pass" events with non-zero elapsed time
-	if event.Action != "pass" || event.Test == "" || event.Elapsed == 0 {
+	switch event.Action {
+	case "pass", "fail", "skip":
+	default:
 		return nil
 	}
 
@@ -34,22 +39,56 @@ func (c *Collector) HandleEvent(event testrunner.TestEvent) error {
 	if idx := strings.Index(pkg, "/pkg/"); idx != -1 {
 		pkg = pkg[idx+1:]
 	}
+	elapsed := time.Duration(event.Elapsed * float64(time.Second))
+
+	// The package finishing gives its overhead, which is whatever time
+	// wasn't spent in its top-level tests
+	if event.Test == "" {
+		if event.Action == "skip" {
+			return nil
+		}
+		overhead := max(elapsed-c.testTime[pkg], 0)
+		delete(c.testTime, pkg)
+		if overhead > 0 {
+			c.Tests = append(c.Tests, Test{Package: pkg, Time: overhead})
+		}
+		return nil
+	}
+
+	if !strings.Contains(event.Test, "/") {
+		if c.testTime == nil {
+			c.testTime = make(map[string]time.Duration)
+		}
+		c.testTime[pkg] += elapsed
+	}
+
+	// Only record passing tests with non-zero elapsed time
+	if event.Action != "pass" || event.Elapsed == 0 {
+		return nil
+	}
 
 	c.Tests = append(c.Tests, Test{
 		Package: pkg,
 		Test:    event.Test,
-		Time:    time.Duration(event.Elapsed * float64(time.Second)),
+		Time:    elapsed,
 	})
 	return nil
 }
 
-// LoadFromFiles loads and aggregates timing data from the given files
-func LoadFromFiles(files []string) (map[string]time.Duration, error) {
-	// Map of test name to average duration
-	timings := make(map[string]struct {
+// Timings is timing data aggregated from one or more files
+type Timings struct {
+	Tests    map[string]time.Duration // Average test durations keyed by package and test, e.g. pkg/example.TestSimple
+	Packages map[string]time.Duration // Average package overheads keyed by package
+}
+
+// Load loads and aggregates timing data from the given files
+func Load(files []string) (*Timings, error) {
+	type average struct {
 		total time.Duration
 		count int
-	})
+	}
+	tests := make(map[string]average)
+	packages := make(map[string]average)
 
 	// Load and aggregate data from each file
 	for _, file := range files {
@@ -64,21 +103,40 @@ func LoadFromFiles(files []string) (map[string]time.Duration, error) {
 		}
 
 		for _, t := range fileTimings {
-			key := t.Package + "." + t.Test
-			entry := timings[key]
+			averages, key := tests, t.Package+"."+t.Test
+			if t.Test == "" {
+				averages, key = packages, t.Package
+			}
+			entry := averages[key]
 			entry.total += t.Time
 			entry.count++
-			timings[key] = entry
+			averages[key] = entry
 		}
 	}
 
 	// Calculate averages
-	result := make(map[string]time.Duration)
-	for key, timing := range timings {
-		result[key] = timing.total / time.Duration(timing.count)
+	timings := &Timings{
+		Tests:    make(map[string]time.Duration, len(tests)),
+		Packages: make(map[string]time.Duration, len(packages)),
+	}
+	for key, a := range tests {
+		timings.Tests[key] = a.total / time.Duration(a.count)
 	}
+	for key, a := range packages {
+		timings.Packages[key] = a.total / time.Duration(a.count)
+	}
+
+	return timings, nil
+}
 
-	return result, nil
+// LoadFromFiles loads and aggregates test timing data from the given files,
+// excluding package overheads
+func LoadFromFiles(files []string) (map[string]time.Duration, error) {
+	timings, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return timings.Tests, nil
 }
 
 // WriteToFile writes test timing data to a JSON file
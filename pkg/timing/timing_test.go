@@ -3,6 +3,7 @@ package timing
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -50,13 +51,26 @@ func TestCollector(t *testing.T) {
 			wantTest: nil,
 		},
 		{
-			name: "pass event with no test name",
+			name: "pass event with no test name is package overhead",
 			event: testrunner.TestEvent{
 				Action:  "pass",
 				Package: "github.com/lox/gotestchunk/pkg/example",
 				Test:    "",
 				Elapsed: 1.5,
 			},
+			wantTest: &Test{
+				Package: "pkg/example",
+				Time:    1500 * time.Millisecond,
+			},
+		},
+		{
+			name: "fail event",
+			event: testrunner.TestEvent{
+				Action:  "fail",
+				Package: "github.com/lox/gotestchunk/pkg/example",
+				Test:    "TestSimple",
+				Elapsed: 1.5,
+			},
 			wantTest: nil,
 		},
 	}
@@ -172,3 +186,107 @@ func TestLoadFromFilesError(t *testing.T) {
 		t.Error("LoadFromFiles() expected error for invalid JSON")
 	}
 }
+
+func TestCollectorPackageOverhead(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []testrunner.TestEvent
+		want   []Test
+	}{
+		{
+			name: "package time beyond its tests",
+			events: []testrunner.TestEvent{
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Test: "TestA/sub", Elapsed: 0.3},
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Test: "TestA", Elapsed: 1},
+				{Action: "fail", Package: "github.com/lox/gotestchunk/pkg/example", Test: "TestB", Elapsed: 0.5},
+				{Action: "fail", Package: "github.com/lox/gotestchunk/pkg/example", Elapsed: 2},
+			},
+			want: []Test{
+				{Package: "pkg/example", Test: "TestA/sub", Time: 300 * time.Millisecond},
+				{Package: "pkg/example", Test: "TestA", Time: time.Second},
+				{Package: "pkg/example", Time: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "parallel tests longer than package",
+			events: []testrunner.TestEvent{
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Test: "TestA", Elapsed: 1},
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Test: "TestB", Elapsed: 1},
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Elapsed: 1.2},
+			},
+			want: []Test{
+				{Package: "pkg/example", Test: "TestA", Time: time.Second},
+				{Package: "pkg/example", Test: "TestB", Time: time.Second},
+			},
+		},
+		{
+			name: "each invocation of a package",
+			events: []testrunner.TestEvent{
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Test: "TestA", Elapsed: 1},
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Elapsed: 1.5},
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Test: "TestB", Elapsed: 2},
+				{Action: "pass", Package: "github.com/lox/gotestchunk/pkg/example", Elapsed: 2.25},
+			},
+			want: []Test{
+				{Package: "pkg/example", Test: "TestA", Time: time.Second},
+				{Package: "pkg/example", Time: 500 * time.Millisecond},
+				{Package: "pkg/example", Test: "TestB", Time: 2 * time.Second},
+				{Package: "pkg/example", Time: 250 * time.Millisecond},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &Collector{}
+			for _, event := range tt.events {
+				if err := collector.HandleEvent(event); err != nil {
+					t.Fatalf("HandleEvent() error = %v", err)
+				}
+			}
+			if !reflect.DeepEqual(collector.Tests, tt.want) {
+				t.Errorf("Collector.Tests = %+v, want %+v", collector.Tests, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "timing-1.json"), filepath.Join(dir, "timing-2.json")}
+
+	if err := WriteToFile([]Test{
+		{Package: "pkg/example", Test: "TestSimple", Time: 100 * time.Millisecond},
+		{Package: "pkg/example", Time: 2 * time.Second},
+	}, files[0]); err != nil {
+		t.Fatalf("WriteToFile() error = %v", err)
+	}
+	if err := WriteToFile([]Test{
+		{Package: "pkg/example", Test: "TestSimple", Time: 300 * time.Millisecond},
+		{Package: "pkg/example", Time: 4 * time.Second},
+	}, files[1]); err != nil {
+		t.Fatalf("WriteToFile() error = %v", err)
+	}
+
+	timings, err := Load(files)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := &Timings{
+		Tests:    map[string]time.Duration{"pkg/example.TestSimple": 200 * time.Millisecond},
+		Packages: map[string]time.Duration{"pkg/example": 3 * time.Second},
+	}
+	if !reflect.DeepEqual(timings, want) {
+		t.Errorf("Load() = %+v, want %+v", timings, want)
+	}
+
+	// Package overheads aren't mistaken for tests
+	tests, err := LoadFromFiles(files)
+	if err != nil {
+		t.Fatalf("LoadFromFiles() error = %v", err)
+	}
+	if !reflect.DeepEqual(tests, want.Tests) {
+		t.Errorf("LoadFromFiles() = %v, want %v", tests, want.Tests)
+	}
+}